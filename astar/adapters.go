@@ -0,0 +1,199 @@
+package astar
+
+import (
+	"errors"
+	"math"
+)
+
+var errNotSquare = errors.New("astar: matrix is not square")
+
+type graphFunc struct {
+	neighbors func(node Node, edges []Edge) ([]Edge, error)
+	heuristic func(start, end Node) (float64, error)
+}
+
+func (g *graphFunc) Neighbors(node Node, edges []Edge) ([]Edge, error) {
+	return g.neighbors(node, edges)
+}
+
+func (g *graphFunc) HeuristicCost(start, end Node) (float64, error) {
+	if g.heuristic == nil {
+		return 0, nil
+	}
+	return g.heuristic(start, end)
+}
+
+// NewGraphFunc returns a Graph that calls the given functions for its
+// Neighbors and HeuristicCost methods. If heuristic is nil then the
+// heuristic cost is always zero.
+func NewGraphFunc(neighbors func(node Node, edges []Edge) ([]Edge, error), heuristic func(start, end Node) (float64, error)) Graph {
+	return &graphFunc{neighbors: neighbors, heuristic: heuristic}
+}
+
+type adjacencyGraph struct {
+	adj       map[Node][]Edge
+	heuristic func(a, b Node) float64
+}
+
+func (g *adjacencyGraph) Neighbors(node Node, edges []Edge) ([]Edge, error) {
+	return append(edges, g.adj[node]...), nil
+}
+
+func (g *adjacencyGraph) HeuristicCost(start, end Node) (float64, error) {
+	if g.heuristic == nil {
+		return 0, nil
+	}
+	return g.heuristic(start, end), nil
+}
+
+// NewAdjacencyGraph returns a Graph backed by an adjacency list mapping
+// each node to its outgoing edges. Nodes missing from the map have no
+// neighbors. If heuristic is nil then the heuristic cost is always zero.
+// The map is not copied so it must not be modified during a search.
+func NewAdjacencyGraph(adj map[Node][]Edge, heuristic func(a, b Node) float64) Graph {
+	return &adjacencyGraph{adj: adj, heuristic: heuristic}
+}
+
+type matrixGraph struct {
+	matrix    [][]float64
+	heuristic func(a, b Node) float64
+}
+
+func (g *matrixGraph) Neighbors(node Node, edges []Edge) ([]Edge, error) {
+	if node < 0 || node >= Node(len(g.matrix)) {
+		return edges, nil
+	}
+	for i, c := range g.matrix[node] {
+		if Node(i) != node && !math.IsInf(c, 1) && !math.IsNaN(c) {
+			edges = append(edges, Edge{Node: Node(i), Cost: c})
+		}
+	}
+	return edges, nil
+}
+
+func (g *matrixGraph) HeuristicCost(start, end Node) (float64, error) {
+	if g.heuristic == nil {
+		return 0, nil
+	}
+	return g.heuristic(start, end), nil
+}
+
+// NewMatrixGraph returns a Graph backed by a square cost matrix where
+// matrix[i][j] is the cost of the edge from node i to node j. A cost of
+// +Inf means there's no edge, and the diagonal is ignored. If heuristic
+// is nil then the heuristic cost is always zero. The matrix is not copied
+// so it must not be modified during a search.
+func NewMatrixGraph(matrix [][]float64, heuristic func(a, b Node) float64) (Graph, error) {
+	for _, row := range matrix {
+		if len(row) != len(matrix) {
+			return nil, errNotSquare
+		}
+	}
+	return &matrixGraph{matrix: matrix, heuristic: heuristic}, nil
+}
+
+type snapshotGraph struct {
+	heuristic Graph
+	// The edges out of node i are edges[offsets[i]:offsets[i+1]]
+	offsets []int
+	edges   []Edge
+}
+
+func (g *snapshotGraph) Neighbors(node Node, edges []Edge) ([]Edge, error) {
+	if node < 0 || node >= Node(g.NodeCount()) {
+		return edges, nil
+	}
+	return append(edges, g.edges[g.offsets[node]:g.offsets[node+1]]...), nil
+}
+
+func (g *snapshotGraph) HeuristicCost(start, end Node) (float64, error) {
+	return g.heuristic.HeuristicCost(start, end)
+}
+
+func (g *snapshotGraph) NodeCount() int {
+	return len(g.offsets) - 1
+}
+
+// Snapshot returns a copy of the edges of every node of a DenseGraph as
+// they are at the time of the call, so a search can run on the copy while
+// the graph itself is being changed, such as by another goroutine. The
+// copy is never modified so it may be searched by any number of
+// goroutines at once. The heuristic isn't copied: HeuristicCost is still
+// called on mp, so it must be safe to call while mp changes, which it is
+// when it only depends on the nodes, and the optional interfaces of mp
+// aren't used. ErrNotDense is returned if mp isn't a DenseGraph.
+func Snapshot(mp Graph) (Graph, error) {
+	dg, ok := mp.(DenseGraph)
+	if !ok {
+		return nil, ErrNotDense
+	}
+	n := dg.NodeCount()
+	g := &snapshotGraph{heuristic: mp, offsets: make([]int, n+1)}
+	var edges []Edge
+	for i := 0; i < n; i++ {
+		var err error
+		// The graph may return a slice of its own so copy the edges
+		edges, err = mp.Neighbors(Node(i), edges[:0])
+		if err != nil {
+			return nil, err
+		}
+		g.edges = append(g.edges, edges...)
+		g.offsets[i+1] = len(g.edges)
+	}
+	return g, nil
+}
+
+// Link is a connection between two nodes for NewLinkGraph. It can be
+// followed in both directions at the same cost unless OneWay is set, in
+// which case it only leads from From to To.
+type Link struct {
+	From, To Node
+	Cost     float64
+	OneWay   bool
+}
+
+// TurnRestriction forbids continuing to To after arriving at Via from
+// From, such as a banned left turn at a junction.
+type TurnRestriction struct {
+	From, Via, To Node
+}
+
+type restrictedGraph struct {
+	adjacencyGraph
+	restrictions map[TurnRestriction]bool
+}
+
+func (g *restrictedGraph) NeighborsFrom(node, parent Node, edges []Edge) ([]Edge, error) {
+	for _, e := range g.adj[node] {
+		if !g.restrictions[TurnRestriction{From: parent, Via: node, To: e.Node}] {
+			edges = append(edges, e)
+		}
+	}
+	return edges, nil
+}
+
+// NewLinkGraph returns a Graph of the given links, such as the roads of
+// a network, where the restricted turns are never taken. Without
+// restrictions it's the same as NewAdjacencyGraph with an edge for each
+// direction a link can be followed. With them the graph is a
+// DirectionalGraph, so a path may visit a node more than once, as when
+// going around a block to make up for a forbidden turn. If heuristic is
+// nil then the heuristic cost is always zero.
+func NewLinkGraph(links []Link, restrictions []TurnRestriction, heuristic func(a, b Node) float64) Graph {
+	adj := make(map[Node][]Edge)
+	for _, l := range links {
+		adj[l.From] = append(adj[l.From], Edge{Node: l.To, Cost: l.Cost})
+		if !l.OneWay {
+			adj[l.To] = append(adj[l.To], Edge{Node: l.From, Cost: l.Cost})
+		}
+	}
+	g := adjacencyGraph{adj: adj, heuristic: heuristic}
+	if len(restrictions) == 0 {
+		return &g
+	}
+	rg := &restrictedGraph{adjacencyGraph: g, restrictions: make(map[TurnRestriction]bool, len(restrictions))}
+	for _, r := range restrictions {
+		rg.restrictions[r] = true
+	}
+	return rg
+}
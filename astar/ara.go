@@ -0,0 +1,206 @@
+package astar
+
+import (
+	"math"
+)
+
+// Amount by which FindPathAnytime lowers the weight after every search.
+const anytimeWeightStep = 0.5
+
+type araNode struct {
+	node   Node
+	parent *araNode
+	g, h   float64
+	index  int  // index in the open list or -1
+	closed bool // expanded by the current search
+	incons bool // in the inconsistent list
+}
+
+// ara holds the state of an ARA* search, which is kept from one search
+// to the next.
+type ara struct {
+	mp       Graph
+	end      Node
+	weight   float64
+	nodes    map[Node]*araNode
+	open     []*araNode
+	incons   []*araNode
+	edges    []Edge
+	expanded int
+}
+
+// FindPathAnytime finds a path from start to end using Anytime
+// Repairing A* (ARA*). It first runs weighted A* with initialWeight,
+// which quickly finds a path costing at most initialWeight times the
+// optimal cost, and then repeatedly lowers the weight and improves the
+// path, reusing the work of the previous searches, until a search with
+// a weight of 1 finds the optimal path. If the graph implements
+// PossiblePath then every path found along the way is passed to it as
+// it's found, so a caller can act on a good enough path before the
+// optimal one is known. Weights less than 1 are treated as 1. The
+// heuristic must be consistent for the final path to be optimal.
+func FindPathAnytime(mp Graph, start, end Node, initialWeight float64) ([]Node, error) {
+	if start == end {
+		return []Node{start}, nil
+	}
+	a := &ara{
+		mp:     mp,
+		end:    end,
+		weight: math.Max(1, initialWeight),
+		nodes:  make(map[Node]*araNode),
+	}
+	root, err := a.get(start)
+	if err != nil {
+		return nil, err
+	}
+	if math.IsInf(root.h, 1) {
+		return nil, errNoPath([]Node{start}, goals{end}, 0)
+	}
+	root.g = 0
+	a.push(root)
+	goal, err := a.get(end)
+	if err != nil {
+		return nil, err
+	}
+	pp, _ := mp.(PossiblePath)
+	best := math.Inf(1)
+	for {
+		if err := a.improvePath(goal); err != nil {
+			return nil, err
+		}
+		if math.IsInf(goal.g, 1) {
+			return nil, errNoPath([]Node{start}, goals{end}, a.expanded)
+		}
+		if pp != nil && goal.g < best {
+			pp.PossiblePath(goal.path(), goal.g)
+		}
+		best = goal.g
+		if a.weight == 1 {
+			return goal.path(), nil
+		}
+		a.weight = math.Max(1, a.weight-anytimeWeightStep)
+		// Nodes whose cost dropped after they were expanded need to be
+		// expanded again by the next search.
+		for _, n := range a.incons {
+			n.incons = false
+			a.push(n)
+		}
+		a.incons = a.incons[:0]
+		for _, n := range a.nodes {
+			n.closed = false
+		}
+		// The order of the open list depends on the weight
+		for i := len(a.open)/2 - 1; i >= 0; i-- {
+			heapDown(a, i, len(a.open))
+		}
+	}
+}
+
+// get returns the record for a node, creating it if it hasn't been seen.
+func (a *ara) get(node Node) (*araNode, error) {
+	if n := a.nodes[node]; n != nil {
+		return n, nil
+	}
+	h, err := a.mp.HeuristicCost(node, a.end)
+	if err != nil {
+		return nil, err
+	}
+	n := &araNode{node: node, g: math.Inf(1), h: h, index: -1}
+	a.nodes[node] = n
+	return n, nil
+}
+
+// improvePath expands nodes until none in the open list could lead to a
+// path cheaper than the one to goal with the current weight.
+func (a *ara) improvePath(goal *araNode) error {
+	for len(a.open) > 0 && a.f(a.open[0]) < goal.g {
+		n := a.pop()
+		n.closed = true
+		a.expanded++
+		edges, err := a.mp.Neighbors(n.node, a.edges[:0])
+		if err != nil {
+			return err
+		}
+		a.edges = edges
+		for _, edge := range edges {
+			if !(edge.Cost >= 0) {
+				return &InvalidCostError{From: n.node, To: edge.Node, Cost: edge.Cost}
+			}
+			if edge.Node == n.node {
+				continue
+			}
+			succ, err := a.get(edge.Node)
+			if err != nil {
+				return err
+			}
+			if math.IsInf(succ.h, 1) {
+				continue
+			}
+			g := n.g + edge.Cost
+			if g >= succ.g {
+				continue
+			}
+			succ.g = g
+			succ.parent = n
+			switch {
+			case succ.index >= 0:
+				heapFix(a, succ.index, len(a.open))
+			case !succ.closed:
+				a.push(succ)
+			case !succ.incons:
+				succ.incons = true
+				a.incons = append(a.incons, succ)
+			}
+		}
+	}
+	return nil
+}
+
+func (a *ara) f(n *araNode) float64 {
+	return n.g + a.weight*n.h
+}
+
+func (a *ara) less(i, j int) bool {
+	ni, nj := a.open[i], a.open[j]
+	if fi, fj := a.f(ni), a.f(nj); fi != fj {
+		return fi < fj
+	}
+	if ni.h != nj.h {
+		return ni.h < nj.h
+	}
+	return ni.node < nj.node
+}
+
+func (a *ara) swap(i, j int) {
+	a.open[i], a.open[j] = a.open[j], a.open[i]
+	a.open[i].index = i
+	a.open[j].index = j
+}
+
+func (a *ara) push(n *araNode) {
+	n.index = len(a.open)
+	a.open = append(a.open, n)
+	heapUp(a, n.index)
+}
+
+func (a *ara) pop() *araNode {
+	last := len(a.open) - 1
+	a.swap(0, last)
+	heapDown(a, 0, last)
+	n := a.open[last]
+	a.open[last] = nil
+	a.open = a.open[:last]
+	n.index = -1
+	return n
+}
+
+func (n *araNode) path() []Node {
+	var path []Node
+	for ; n != nil; n = n.parent {
+		path = append(path, n.node)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
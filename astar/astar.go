@@ -1,54 +1,535 @@
 package astar
 
 import (
+	"context"
+	"errors"
 	"math"
+	"math/rand"
+	"sort"
+	"time"
 )
 
 const (
 	maxDefaultMapCapacity = 131072
 	defaultListCapacity   = 4096
+
+	// Node records are allocated in slabs which start small so short
+	// searches stay cheap and double up to the max size.
+	minSlabSize = 64
+	maxSlabSize = 4096
+
+	// Number of node expansions between checks for cancellation. Must
+	// be a power of two.
+	cancelCheckInterval = 1024
+
+	// Number of node expansions between calls to Progress.
+	progressInterval = 256
 )
 
-type nodeInfo struct {
+// OpenNode is the record a search keeps for a node it has reached.
+type OpenNode struct {
 	node          Node
-	parent        Node    // the node from which we came to get here
-	index         int     // index of the node in the heap
-	cost          float32 // current cost from start node to this node
-	predictedCost float32 // heuristic cost from this node to end node
+	parent        *OpenNode // the node from which we came to get here (nil for the start)
+	index         int       // index of the node in the heap or -1 once closed
+	cost          float64   // current cost from start node to this node
+	predictedCost float64   // heuristic cost from this node to end node
+	length        int       // number of edges on the path from the start
+	estimated     bool      // predictedCost is a lower bound from the parent
+}
+
+// Node returns the ID of the node.
+func (ni *OpenNode) Node() Node {
+	return ni.node
+}
+
+// Cost returns the cost of the cheapest path to the node found so far.
+func (ni *OpenNode) Cost() float64 {
+	return ni.cost
+}
+
+// PredictedCost returns the heuristic cost from the node to the goal.
+func (ni *OpenNode) PredictedCost() float64 {
+	return ni.predictedCost
+}
+
+// Less reports whether ni should be expanded before o. This is the order
+// used by FindPath, including the way it breaks ties.
+func (ni *OpenNode) Less(o *OpenNode) bool {
+	return lessNodes(ni, o, 1)
+}
+
+// OpenList is a priority queue of the nodes a search has reached but not
+// yet expanded. The best node, as reported by OpenNode.Less, must be
+// removed first.
+type OpenList interface {
+	// Push adds a node to the list.
+	Push(ni *OpenNode)
+	// PopBest removes and returns the best node in the list.
+	PopBest() *OpenNode
+	// Update is called when the cost of a node in the list decreases.
+	Update(ni *OpenNode)
+	Len() int
+}
+
+// parentNode returns the ID of the parent node or -1 for a start node.
+func (ni *OpenNode) parentNode() Node {
+	if ni.parent == nil {
+		return -1
+	}
+	return ni.parent.node
+}
+
+// Stats describes the work performed by a search.
+type Stats struct {
+	NodesExpanded  int // nodes popped from the open list whose neighbors were generated
+	NodesGenerated int // distinct nodes added to the open list
+	MaxOpenSize    int // largest size reached by the open list
+	CostUpdates    int // times a cheaper path to an already seen node was found
+	InvalidEdges   int // edges skipped because a Validator reported their node invalid
+}
+
+// PathNode is a node on a path along with the total cost of the path up
+// to and including it.
+type PathNode struct {
+	Node Node
+	Cost float64
 }
 
 type state struct {
-	info    map[Node]*nodeInfo
-	heap    []*nodeInfo
-	maxCost float32
+	info  map[Node]*OpenNode
+	dense []*OpenNode // lookup for nodes 0..len(dense)-1 of a DenseGraph
+	// When turn costs or directional neighbors are used a node is tracked
+	// separately for every node it's entered from, in turns instead of
+	// info and dense.
+	turns    map[turnKey]*OpenNode
+	useTurns bool
+	// Heuristic costs by node when the graph allows caching them and a
+	// node may be tracked more than once.
+	heuristics      map[Node]float64
+	cacheHeuristics bool
+	heuristic       func(Node) float64 // replaces the graph's heuristic if set
+	scale           float64            // multiplies the graph's heuristic if not 0
+	// The open list is a binary heap maintained by the same functions as
+	// PriorityQueue but holding OpenNode directly to avoid an interface
+	// call and map lookup per comparison.
+	heap    []*OpenNode
+	open    OpenList // replaces heap if set
+	maxCost float64
+	weight  float64 // inflation factor applied to predictedCost
+	greedy  bool    // order the open list by predictedCost alone
+	stats   Stats
+
+	// Slab allocator for node records. The slabs are kept across a reset
+	// so a reused state doesn't allocate node records at all.
+	slabs    [][]OpenNode
+	nextSlab int
+	free     []OpenNode
+	records  int // node records handed out since the last reset
+}
+
+// config holds the optional parameters of a search.
+type config struct {
+	ctx     context.Context
+	stop    <-chan struct{} // cancels the search with ErrCancelled
+	stats   *Stats
+	weight  float64
+	partial bool // return the path to the closest node if end is unreachable
+
+	explored map[Node]float64 // filled with the cost of every closed node
+	tree     map[Node]Node    // filled with the parent of every reached node
+
+	state *state // reused state instead of allocating a new one
+
+	los LineOfSightGraph // enables any-angle (Theta*) relaxation
+
+	open OpenList // used instead of the built-in binary heap
+
+	maxNodes int     // maximum number of nodes to pop from the open list (0 for no limit)
+	maxCost  float64 // paths must cost less than this (0 for no limit)
+
+	maxLength int // paths may have at most this many edges (0 for no limit)
+
+	copyEdges bool // pass a new edges slice to every call of Neighbors
+
+	rand *rand.Rand // chooses between paths of equal cost if set
+
+	scale float64 // multiplies the graph's heuristic costs (0 or 1 for none)
+
+	blockedNodes map[Node]bool    // nodes that can't be entered
+	blockedEdges map[[2]Node]bool // edges from [0] to [1] that can't be followed
+
+	seeds []SeededNode // nodes added to the open list before the starts
+
+	// Switch to greedy best-first search after popping this many nodes
+	// or once this much time has passed (0 for never)
+	greedyAfterNodes int
+	greedyAfterTime  time.Duration
+
+	checkGoal bool // fail if the heuristic cost of an end to itself isn't 0
+
+	tolerance float64 // stop at the first node within this heuristic cost of an end
+
+	beam int // keep only this many of the best nodes in the open list (0 for no limit)
+
+	debug      Debug // used instead of the graph's Debug if set
+	debugEvery int   // only pass every nth expanded node to Debug
+
+	isGoal    func(Node) bool    // also terminates at nodes for which it's true
+	heuristic func(Node) float64 // used instead of the graph's heuristic if set
+}
+
+// Options are the optional parameters of FindPathOpts. The zero value
+// finds the same path as FindPath.
+type Options struct {
+	// HeuristicWeight multiplies the heuristic cost as in
+	// FindPathWeighted. Weights less than 1 are treated as 1.
+	HeuristicWeight float64
+	// MaxCost limits the search to paths that cost less than it as in
+	// FindPathMaxCost. Zero or less means no limit.
+	MaxCost float64
+	// MaxNodes limits the number of nodes removed from the open list as
+	// in FindPathLimited. Zero or less means no limit.
+	MaxNodes int
+	// Debug receives every expanded node instead of the graph, if the
+	// graph implements Debug itself.
+	Debug Debug
+	// DebugEvery only passes every nth expanded node to Debug, starting
+	// with the first, so huge searches can be followed without being
+	// drowned in calls. Zero or less passes every node.
+	DebugEvery int
+	// IgnoreHeuristic finds the path with Dijkstra's algorithm by using a
+	// heuristic cost of 0 for every node, so the graph's HeuristicCost is
+	// never called.
+	IgnoreHeuristic bool
+	// MaxPathLength limits paths to at most this many edges, and if
+	// every path found to the end is longer ErrPathTooLong is returned.
+	// Since a node is only tracked along the cheapest path to it found
+	// so far, a longer but cheaper path can hide a shorter one through
+	// the same node, so the path found isn't always the cheapest one
+	// within the limit and ErrPathTooLong doesn't prove there's none.
+	// Zero or less means no limit.
+	MaxPathLength int
+	// CopyEdges passes a nil edges slice to every call of Neighbors
+	// instead of reusing one, so the graph may keep the slices it
+	// returns. This allocates for every expanded node.
+	CopyEdges bool
+	// Rand, if set, is used to choose at random between paths of equal
+	// cost to a node instead of always keeping the first one found, so
+	// repeated searches spread out over all the cheapest paths rather
+	// than following the same one. Every path of the same cost to a node
+	// that's found while the node is open is equally likely to be kept.
+	// The path is still optimal but no longer the same from one search
+	// to the next unless Rand is seeded the same way. It's nil by
+	// default.
+	Rand *rand.Rand
+	// CostScale multiplies every heuristic cost returned by the graph, to
+	// bring the heuristic into the same units as the edge costs without
+	// changing it, for instance when edge costs are in tenths of a cell
+	// but the heuristic counts cells. Unlike HeuristicWeight it's meant
+	// to make the heuristic more accurate rather than to trade optimality
+	// for speed, so it may be less than 1, but the path is only optimal
+	// if the scaled heuristic is admissible. Zero or less means a scale
+	// of 1.
+	CostScale float64
+	// BlockedNodes are nodes that can't be entered during this search, as
+	// if every edge into them were missing. Blocking a start has no
+	// effect.
+	BlockedNodes map[Node]bool
+	// BlockedEdges are edges that can't be followed during this search,
+	// each given by the node it leaves and the node it enters. The edge
+	// in the other direction isn't blocked unless it's also listed.
+	BlockedEdges map[[2]Node]bool
+	// GreedyAfterNodes and GreedyAfterTime switch the search to greedy
+	// best-first search, which expands the node with the lowest
+	// heuristic cost first whatever the cost of reaching it, once it has
+	// removed that many nodes from the open list or run for that long.
+	// This usually reaches the end much sooner, so a search under time
+	// pressure still finds a path, but the path may be far from optimal
+	// and it's returned along with ErrGreedyPath. Zero or less means no
+	// limit. The time is only checked every thousand or so nodes.
+	GreedyAfterNodes int
+	GreedyAfterTime  time.Duration
+	// CheckGoalHeuristic calls HeuristicCost(end, end) once before the
+	// search and returns ErrBadHeuristic if it isn't zero, which catches
+	// a heuristic that would make the search expand nodes around the end
+	// without any need. It's ignored with IgnoreHeuristic.
+	CheckGoalHeuristic bool
 }
 
-func (s *state) pathToNode(node *nodeInfo) []Node {
-	path := make([]Node, 0, 128)
-	for n := node; n != nil; n = s.info[n.parent] {
-		path = append(path, n.node)
+func zeroCost(Node) float64 {
+	return 0
+}
+
+func (o Options) config() config {
+	cfg := config{
+		weight:           o.HeuristicWeight,
+		maxCost:          o.MaxCost,
+		maxNodes:         o.MaxNodes,
+		debug:            o.Debug,
+		debugEvery:       o.DebugEvery,
+		maxLength:        o.MaxPathLength,
+		copyEdges:        o.CopyEdges,
+		rand:             o.Rand,
+		scale:            o.CostScale,
+		blockedNodes:     o.BlockedNodes,
+		blockedEdges:     o.BlockedEdges,
+		greedyAfterNodes: o.GreedyAfterNodes,
+		greedyAfterTime:  o.GreedyAfterTime,
+		checkGoal:        o.CheckGoalHeuristic,
+	}
+	if o.IgnoreHeuristic {
+		cfg.heuristic = zeroCost
+	}
+	return cfg
+}
+
+// errParentCycle is returned if following the parents of a node never
+// reaches a start node, which would mean the search state is corrupt.
+var errParentCycle = errors.New("astar: internal error: cycle in the parents of a node")
+
+func (s *state) pathToNode(node *OpenNode) ([]Node, error) {
+	return s.appendPath(make([]Node, 0, 128), node)
+}
+
+// appendPath appends the path from the start to node to dst.
+func (s *state) appendPath(dst []Node, node *OpenNode) ([]Node, error) {
+	start := len(dst)
+	for n := node; n != nil; n = n.parent {
+		// A path can't visit more nodes than the search has seen
+		if len(dst)-start == s.records {
+			return dst[:start], errParentCycle
+		}
+		dst = append(dst, n.node)
 	}
 	// Reverse the path since we built it backwards
+	path := dst[start:]
 	n := len(path) / 2
 	for i := 0; i < n; i++ {
 		j := len(path) - i - 1
 		path[i], path[j] = path[j], path[i]
 	}
-	return path
+	return dst, nil
+}
+
+// detailedPath returns the path from the start to node along with the
+// cost of reaching each node.
+func (s *state) detailedPath(node *OpenNode) ([]PathNode, error) {
+	n := 0
+	for ni := node; ni != nil; ni = ni.parent {
+		if n == s.records {
+			return nil, errParentCycle
+		}
+		n++
+	}
+	path := make([]PathNode, n)
+	for ni := node; ni != nil; ni = ni.parent {
+		n--
+		path[n] = PathNode{Node: ni.node, Cost: ni.cost}
+	}
+	return path, nil
 }
 
 func newState(capacity int) *state {
 	return &state{
-		info:    make(map[Node]*nodeInfo, capacity),
-		heap:    make([]*nodeInfo, 0, defaultListCapacity),
-		maxCost: float32(math.Inf(1)),
+		info:    make(map[Node]*OpenNode, capacity),
+		heap:    make([]*OpenNode, 0, defaultListCapacity),
+		maxCost: math.Inf(1),
+		weight:  1,
+	}
+}
+
+// setDense switches node lookups for IDs below n from the info map to
+// a slice indexed by node ID.
+func (s *state) setDense(n int) {
+	if cap(s.dense) >= n {
+		s.dense = s.dense[:n]
+	} else {
+		s.dense = make([]*OpenNode, n)
+	}
+}
+
+// turnKey identifies a node along with the node it was entered from.
+type turnKey struct {
+	node, from Node
+	start      bool // start nodes aren't entered from anywhere
+}
+
+func (ni *OpenNode) turnKey() turnKey {
+	if ni.parent == nil {
+		return turnKey{node: ni.node, start: true}
+	}
+	return turnKey{node: ni.node, from: ni.parent.node}
+}
+
+// lookup returns the record for a node entered from parent (nil for a
+// start node) or nil if it hasn't been seen. Unless turn costs are used
+// the parent is ignored.
+func (s *state) lookup(node Node, parent *OpenNode) *OpenNode {
+	if !s.useTurns {
+		return s.get(node)
+	}
+	if parent == nil {
+		return s.turns[turnKey{node: node, start: true}]
+	}
+	return s.turns[turnKey{node: node, from: parent.node}]
+}
+
+// heuristicCost returns the heuristic cost from node to the closest of
+// the ends, remembering it if the heuristic is cached.
+func (s *state) heuristicCost(mp Graph, ends goals, node Node) (float64, error) {
+	if s.cacheHeuristics {
+		if h, ok := s.heuristics[node]; ok {
+			return h, nil
+		}
+	}
+	var h float64
+	if s.heuristic != nil {
+		h = s.heuristic(node)
+	} else {
+		var err error
+		if h, err = ends.heuristicCost(mp, node); err != nil {
+			return 0, err
+		}
+		if s.scale != 0 {
+			h *= s.scale
+		}
+	}
+	if s.cacheHeuristics {
+		s.heuristics[node] = h
+	}
+	return h, nil
+}
+
+// get returns the record for a node or nil if it hasn't been seen.
+func (s *state) get(node Node) *OpenNode {
+	if uint64(node) < uint64(len(s.dense)) {
+		return s.dense[node]
+	}
+	return s.info[node]
+}
+
+func (s *state) set(ni *OpenNode) {
+	if s.useTurns {
+		s.turns[ni.turnKey()] = ni
+	} else if uint64(ni.node) < uint64(len(s.dense)) {
+		s.dense[ni.node] = ni
+	} else {
+		s.info[ni.node] = ni
 	}
 }
 
+// each calls fn for the record of every node seen by the search.
+func (s *state) each(fn func(ni *OpenNode)) {
+	for i := 0; i < s.nextSlab; i++ {
+		slab := s.slabs[i]
+		if i == s.nextSlab-1 {
+			slab = slab[:len(slab)-len(s.free)]
+		}
+		for j := range slab {
+			fn(&slab[j])
+		}
+	}
+}
+
+// reset clears the state for reuse by another search while keeping
+// the memory allocated for the info map and heap.
+func (s *state) reset() {
+	for n := range s.info {
+		delete(s.info, n)
+	}
+	for k := range s.turns {
+		delete(s.turns, k)
+	}
+	s.useTurns = false
+	for n := range s.heuristics {
+		delete(s.heuristics, n)
+	}
+	s.cacheHeuristics = false
+	s.heuristic = nil
+	s.scale = 0
+	// Only the entries for nodes that were seen need clearing
+	s.each(func(ni *OpenNode) {
+		if uint64(ni.node) < uint64(len(s.dense)) {
+			s.dense[ni.node] = nil
+		}
+	})
+	s.dense = s.dense[:0]
+	for i := range s.heap {
+		s.heap[i] = nil
+	}
+	s.heap = s.heap[:0]
+	s.open = nil
+	s.maxCost = math.Inf(1)
+	s.weight = 1
+	s.greedy = false
+	s.stats = Stats{}
+	s.nextSlab = 0
+	s.free = nil
+	s.records = 0
+}
+
+// newNodeInfo returns a node record from the slab allocator. Since
+// records are recycled the caller must initialize every field.
+func (s *state) newNodeInfo() *OpenNode {
+	if len(s.free) == 0 {
+		if s.nextSlab == len(s.slabs) {
+			size := minSlabSize
+			if n := len(s.slabs); n > 0 {
+				size = 2 * len(s.slabs[n-1])
+				if size > maxSlabSize {
+					size = maxSlabSize
+				}
+			}
+			s.slabs = append(s.slabs, make([]OpenNode, size))
+		}
+		s.free = s.slabs[s.nextSlab]
+		s.nextSlab++
+	}
+	ni := &s.free[0]
+	s.free = s.free[1:]
+	s.records++
+	return ni
+}
+
+// less orders the open list by the sum of the current and (weighted)
+// predicted cost. Ties are broken deterministically by preferring the
+// node with the lower predicted cost (closer to the goal) and then the
+// node with the lower ID, so equal cost paths are always chosen the same
+// way regardless of the order nodes were added.
 func (nl *state) less(i, j int) bool {
-	li := nl.heap[i]
-	lj := nl.heap[j]
-	return (li.cost + li.predictedCost) < (lj.cost + lj.predictedCost)
+	return nl.lessNodes(nl.heap[i], nl.heap[j])
+}
+
+// lessNodes orders two nodes the way the open list does.
+func (nl *state) lessNodes(li, lj *OpenNode) bool {
+	if nl.greedy {
+		return lessGreedy(li, lj)
+	}
+	return lessNodes(li, lj, nl.weight)
+}
+
+// lessGreedy orders nodes by predicted cost alone as in greedy best-first
+// search, breaking ties by the current cost and then the node ID.
+func lessGreedy(li, lj *OpenNode) bool {
+	if li.predictedCost != lj.predictedCost {
+		return li.predictedCost < lj.predictedCost
+	}
+	if li.cost != lj.cost {
+		return li.cost < lj.cost
+	}
+	return li.node < lj.node
+}
+
+func lessNodes(li, lj *OpenNode, weight float64) bool {
+	fi := li.cost + weight*li.predictedCost
+	fj := lj.cost + weight*lj.predictedCost
+	if fi != fj {
+		return fi < fj
+	}
+	if li.predictedCost != lj.predictedCost {
+		return li.predictedCost < lj.predictedCost
+	}
+	return li.node < lj.node
 }
 
 func (nl *state) swap(i, j int) {
@@ -58,162 +539,883 @@ func (nl *state) swap(i, j int) {
 	l[j].index = j
 }
 
-func (nl *state) up(j int) {
-	for {
-		i := (j - 1) / 2 // parent
-		if i == j || !nl.less(j, i) {
-			break
-		}
-		nl.swap(i, j)
-		j = i
+func (nl *state) openLen() int {
+	if nl.open != nil {
+		return nl.open.Len()
 	}
+	return len(nl.heap)
 }
 
-func (nl *state) down(i, n int) {
-	for {
-		j1 := 2*i + 1
-		if j1 >= n || j1 < 0 { // j1 < 0 after int overflow
-			break
-		}
-		j := j1 // left child
-		if j2 := j1 + 1; j2 < n && !nl.less(j1, j2) {
-			j = j2 // = 2*i + 2  // right child
-		}
-		if !nl.less(j, i) {
-			break
+func (nl *state) popBest() *OpenNode {
+	if nl.open != nil {
+		if nl.open.Len() == 0 {
+			return nil
 		}
-		nl.swap(i, j)
-		i = j
+		v := nl.open.PopBest()
+		v.index = -1
+		return v
 	}
-}
-
-func (nl *state) popBest() *nodeInfo {
 	n := len(nl.heap) - 1
 	if n < 0 {
 		return nil
 	}
 	nl.swap(0, n)
-	nl.down(0, n)
+	heapDown(nl, 0, n)
 	v := nl.heap[n]
 	nl.heap = nl.heap[:n]
 	v.index = -1
 	return v
 }
 
-func (nl *state) addNodeInfo(ni *nodeInfo) {
-	nl.info[ni.node] = ni
+func (nl *state) addNodeInfo(ni *OpenNode) {
+	nl.set(ni)
+	if nl.open != nil {
+		ni.index = 0 // any index >= 0 marks it as open
+		nl.open.Push(ni)
+		if n := nl.open.Len(); n > nl.stats.MaxOpenSize {
+			nl.stats.MaxOpenSize = n
+		}
+		return
+	}
 	nl.heap = append(nl.heap, ni)
 	i := len(nl.heap) - 1
 	ni.index = i
-	nl.up(i)
+	heapUp(nl, i)
+	if len(nl.heap) > nl.stats.MaxOpenSize {
+		nl.stats.MaxOpenSize = len(nl.heap)
+	}
 }
 
-func (nl *state) updateNodeInfo(ni *nodeInfo) {
-	index := ni.index
-	n := len(nl.heap)
-	nl.down(index, n)
-	nl.up(index)
+// trim drops all but the best width nodes from the open list. Dropped
+// nodes are marked closed so they're only reopened by a cheaper path.
+func (nl *state) trim(width int) {
+	if len(nl.heap) <= width {
+		return
+	}
+	sort.Slice(nl.heap, func(i, j int) bool {
+		return nl.lessNodes(nl.heap[i], nl.heap[j])
+	})
+	for i := width; i < len(nl.heap); i++ {
+		nl.heap[i].index = -1
+		nl.heap[i] = nil
+	}
+	nl.heap = nl.heap[:width]
+	// A sorted slice is already a valid heap
+	for i, ni := range nl.heap {
+		ni.index = i
+	}
+}
+
+// setGreedy switches the open list to greedy best-first order.
+func (nl *state) setGreedy() {
+	nl.greedy = true
+	for i := len(nl.heap)/2 - 1; i >= 0; i-- {
+		heapDown(nl, i, len(nl.heap))
+	}
+}
+
+// updateNodeInfo restores the order of the open list after the cost of
+// a node decreased. A node that was already popped has an index of -1 so
+// it's added back instead of corrupting the heap.
+func (nl *state) updateNodeInfo(ni *OpenNode) {
+	if ni.index < 0 {
+		nl.addNodeInfo(ni)
+		return
+	}
+	if nl.open != nil {
+		nl.open.Update(ni)
+		return
+	}
+	heapFix(nl, ni.index, len(nl.heap))
 }
 
 // Find the optimal path through the graph from start to end and
 // return the nodes in order for the path. If no path is found
 // because it's impossible to reach end from start then return an error.
+//
+// When several paths have the same cost the search prefers nodes closer
+// to the goal by heuristic and then nodes with lower IDs, so the same
+// query on the same graph always returns the same path.
+//
+// If start and end are the same node then the path is just that node and
+// the graph isn't searched.
 func FindPath(mp Graph, start, end Node) ([]Node, error) {
-	mapCapacity := int(end - start)
-	if mapCapacity < 0 {
-		mapCapacity = -mapCapacity
+	return FindPathContext(context.Background(), mp, start, end)
+}
+
+// FindPathContext is like FindPath but stops the search and returns
+// ctx.Err() if the context is cancelled before a path is found.
+func FindPathContext(ctx context.Context, mp Graph, start, end Node) ([]Node, error) {
+	path, _, err := findPath(mp, start, end, config{ctx: ctx})
+	return path, err
+}
+
+// FindPathStop is like FindPath but stops the search and returns
+// ErrCancelled if stop is closed or receives a value before a path is
+// found. The channel is only polled, a few times during the search, so
+// nothing is left waiting on it once FindPathStop returns.
+func FindPathStop(mp Graph, start, end Node, stop <-chan struct{}) ([]Node, error) {
+	path, _, err := findPath(mp, start, end, config{stop: stop})
+	return path, err
+}
+
+// FindPathCost is like FindPath but also returns the total cost of
+// the path as accumulated by the search.
+func FindPathCost(mp Graph, start, end Node) ([]Node, float64, error) {
+	return findPath(mp, start, end, config{})
+}
+
+// FindPathStats is like FindPath but also returns statistics about the
+// search. The stats are filled in even when no path is found.
+func FindPathStats(mp Graph, start, end Node) ([]Node, Stats, error) {
+	var stats Stats
+	path, _, err := findPath(mp, start, end, config{stats: &stats})
+	return path, stats, err
+}
+
+// FindPathWeighted runs weighted A*, multiplying the heuristic cost by
+// weight when ordering the open list. A weight of 1.0 is standard A*.
+// Larger weights bias the search toward the goal which usually expands
+// far fewer nodes, at the cost of optimality: for an admissible heuristic
+// the returned path costs at most weight times the optimal cost.
+// Weights less than 1.0 are treated as 1.0.
+func FindPathWeighted(mp Graph, start, end Node, weight float64) ([]Node, error) {
+	return FindPathOpts(mp, start, end, Options{HeuristicWeight: weight})
+}
+
+// FindPathOpts is like FindPath but with the optional parameters of the
+// search given by opts, which allows combining them.
+func FindPathOpts(mp Graph, start, end Node, opts Options) ([]Node, error) {
+	path, _, err := findPath(mp, start, end, opts.config())
+	return path, err
+}
+
+// FindPathAny finds the optimal path from start to whichever of the
+// ends is cheapest to reach. The heuristic used for a node is the
+// minimum of its heuristic cost to each of the ends. The index into ends
+// of the end that was reached is returned along with the path. If the
+// same node appears more than once in ends the first index is returned.
+func FindPathAny(mp Graph, start Node, ends []Node) ([]Node, int, error) {
+	if len(ends) == 0 {
+		return nil, -1, ErrImpossible
 	}
-	if mapCapacity > maxDefaultMapCapacity {
-		mapCapacity = maxDefaultMapCapacity
+	path, _, err := findPathGoals(mp, []Node{start}, goals(ends), config{})
+	if err != nil {
+		return nil, -1, err
 	}
-	// The open list is ordered by the sum of current cost + heuristic cost
-	state := newState(mapCapacity)
-	// Add the start node to the openlist
-	pCost, err := mp.HeuristicCost(start, end)
+	for i, e := range ends {
+		if e == path[len(path)-1] {
+			return path, i, nil
+		}
+	}
+	return path, -1, nil
+}
+
+// FindPathFromAny finds the optimal path to end from whichever of the
+// starts is cheapest. All starts are added to the open list with a cost
+// of zero. The index into starts of the start that was used is returned
+// along with the path.
+func FindPathFromAny(mp Graph, starts []Node, end Node) ([]Node, int, error) {
+	if len(starts) == 0 {
+		return nil, -1, ErrImpossible
+	}
+	path, _, err := findPathGoals(mp, starts, goals{end}, config{})
+	if err != nil {
+		return nil, -1, err
+	}
+	for i, s := range starts {
+		if s == path[0] {
+			return path, i, nil
+		}
+	}
+	return path, -1, nil
+}
+
+// FindPathFunc finds the optimal path from start to the cheapest node to
+// reach for which isGoal returns true, which allows goals that can't be
+// listed up front. The heuristic returns the estimated cost from a node
+// to the closest goal and must be admissible for the path to be optimal.
+// If it's nil the heuristic is 0, making the search Dijkstra's algorithm.
+// The graph's own HeuristicCost is never called.
+func FindPathFunc(mp Graph, start Node, isGoal func(Node) bool, heuristic func(Node) float64) ([]Node, error) {
+	if heuristic == nil {
+		heuristic = func(Node) float64 { return 0 }
+	}
+	path, _, err := findPathGoals(mp, []Node{start}, nil, config{isGoal: isGoal, heuristic: heuristic})
+	return path, err
+}
+
+// goals is the set of nodes at which a search terminates.
+type goals []Node
+
+func (g goals) contains(node Node) bool {
+	for _, n := range g {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
+
+// heuristicCost returns the minimum heuristic cost from node to any goal.
+// Without goals it's 0 so the search floods the whole graph.
+func (g goals) heuristicCost(mp Graph, node Node) (float64, error) {
+	if len(g) == 0 {
+		return 0, nil
+	}
+	best := math.Inf(1)
+	for _, end := range g {
+		c, err := mp.HeuristicCost(node, end)
+		if err != nil {
+			return 0, err
+		}
+		if c < best {
+			best = c
+		}
+	}
+	return best, nil
+}
+
+// lowerBound returns the minimum lower bound on the heuristic cost from
+// node to any goal.
+func (s *state) lowerBound(eh ExpensiveHeuristic, ends goals, node Node) float64 {
+	if len(ends) == 0 || s.heuristic != nil {
+		return 0
+	}
+	best := math.Inf(1)
+	for _, end := range ends {
+		best = math.Min(best, eh.HeuristicLowerBound(node, end))
+	}
+	if s.scale != 0 {
+		best *= s.scale
+	}
+	return best
+}
+
+// FindPathPartial is like FindPathCost but when end is unreachable it
+// returns the path to the explored node with the lowest heuristic cost
+// to end, along with its cost and ErrImpossible. This lets a caller move
+// as close to an unreachable goal as possible.
+func FindPathPartial(mp Graph, start, end Node) ([]Node, float64, error) {
+	return findPath(mp, start, end, config{partial: true})
+}
+
+// FindPathExplored is like FindPath but also returns every node that
+// was removed from the open list during the search (including end if it
+// was reached) mapped to its final cost. The explored nodes are returned
+// even when no path is found.
+func FindPathExplored(mp Graph, start, end Node) ([]Node, map[Node]float64, error) {
+	explored := make(map[Node]float64)
+	path, _, err := findPath(mp, start, end, config{explored: explored})
+	return path, explored, err
+}
+
+// FindPathTree is like FindPath but also returns the search tree: every
+// node the search reached, whether or not it was expanded, mapped to the
+// node it was reached from. The start has no parent so it isn't in the
+// map. The tree is returned even when no path is found.
+func FindPathTree(mp Graph, start, end Node) ([]Node, map[Node]Node, error) {
+	tree := make(map[Node]Node)
+	path, _, err := findPath(mp, start, end, config{tree: tree})
+	return path, tree, err
+}
+
+// FindPathLimited is like FindPath but gives up and returns
+// ErrBudgetExceeded if it removes more than maxNodes nodes from the
+// open list. This bounds the worst-case runtime of a search.
+func FindPathLimited(mp Graph, start, end Node, maxNodes int) ([]Node, error) {
+	if maxNodes <= 0 {
+		return nil, ErrBudgetExceeded
+	}
+	return FindPathOpts(mp, start, end, Options{MaxNodes: maxNodes})
+}
+
+// FindPathMaxCost is like FindPath but only finds paths that cost
+// less than maxCost. Nodes that can't be reached for less than maxCost
+// are never added to the open list. If there's no path under the budget
+// then ErrImpossible is returned.
+func FindPathMaxCost(mp Graph, start, end Node, maxCost float64) ([]Node, error) {
+	if maxCost <= 0 {
+		return nil, ErrImpossible
+	}
+	return FindPathOpts(mp, start, end, Options{MaxCost: maxCost})
+}
+
+// FindPathNear is like FindPath but stops as soon as it removes a node
+// from the open list whose heuristic cost to end is at most tolerance
+// and returns the path to that node. This is useful when getting close
+// to end is good enough and can be much cheaper than reaching it, for
+// instance when end is hard to get into. A tolerance of 0 is the same
+// as FindPath.
+func FindPathNear(mp Graph, start, end Node, tolerance float64) ([]Node, error) {
+	path, _, err := findPath(mp, start, end, config{tolerance: tolerance})
+	return path, err
+}
+
+// FindPathBeam runs a beam search: A* that keeps only the width nodes
+// with the lowest f cost in the open list after every expansion and
+// forgets the rest. This bounds the memory used by the open list and
+// usually finds a path much faster, but the path is not necessarily the
+// optimal one and, since the nodes that lead around an obstacle may be
+// dropped, the search can fail even though a path exists. A wider beam
+// makes both less likely. ErrImpossible is returned if the beam empties
+// before reaching end. A width less than 1 is treated as 1.
+func FindPathBeam(mp Graph, start, end Node, width int) ([]Node, error) {
+	if width < 1 {
+		width = 1
+	}
+	path, _, err := findPath(mp, start, end, config{beam: width})
+	return path, err
+}
+
+// FindPathWith is like FindPath but uses ol as the open list instead of
+// the built-in binary heap, which allows experimenting with other
+// priority queues. The list must be empty.
+func FindPathWith(mp Graph, start, end Node, ol OpenList) ([]Node, error) {
+	path, _, err := findPath(mp, start, end, config{open: ol})
+	return path, err
+}
+
+// FindPathInto is like FindPath but stores the path in dst[:0] which
+// lets a caller reuse one buffer across many searches. The returned
+// slice is a new allocation if dst didn't have enough capacity.
+func FindPathInto(mp Graph, start, end Node, dst []Node) ([]Node, error) {
+	if start == end {
+		return append(dst[:0], start), nil
+	}
+	state, ni, err := search(mp, []Node{start}, goals{end}, config{})
+	if err != nil {
+		return dst[:0], err
+	}
+	return state.appendPath(dst[:0], ni)
+}
+
+// FindPathDetailed is like FindPath but returns the cost of the path up
+// to each node along with the node. The cost of the first node is 0 and
+// that of the last is the cost of the whole path.
+func FindPathDetailed(mp Graph, start, end Node) ([]PathNode, error) {
+	if start == end {
+		return []PathNode{{Node: start}}, nil
+	}
+	state, ni, err := search(mp, []Node{start}, goals{end}, config{})
 	if err != nil {
 		return nil, err
 	}
-	state.addNodeInfo(&nodeInfo{
-		node:          start,
-		parent:        -1,
-		cost:          0.0,
-		predictedCost: float32(pCost),
-	})
+	return state.detailedPath(ni)
+}
+
+func findPath(mp Graph, start, end Node, cfg config) ([]Node, float64, error) {
+	// Without a search the graph isn't touched at all
+	if start == end {
+		if cfg.stats != nil {
+			*cfg.stats = Stats{}
+		}
+		if cfg.explored != nil {
+			cfg.explored[start] = 0
+		}
+		return []Node{start}, 0, nil
+	}
+	return findPathGoals(mp, []Node{start}, goals{end}, cfg)
+}
+
+// cheapestEdges appends edges to dst keeping only the cheapest edge to
+// each node, in the position of the first edge to the node.
+func cheapestEdges(dst, edges []Edge) []Edge {
+	start := len(dst)
+next:
+	for _, e := range edges {
+		for i := start; i < len(dst); i++ {
+			if dst[i].Node == e.Node {
+				// NaN costs are kept so they're reported
+				if e.Cost < dst[i].Cost || e.Cost != e.Cost {
+					dst[i].Cost = e.Cost
+				}
+				continue next
+			}
+		}
+		dst = append(dst, e)
+	}
+	return dst
+}
+
+// defaultCapacity guesses the number of nodes a search between a and b
+// will reach from the distance between their IDs, up to
+// maxDefaultMapCapacity. The distance is computed without overflowing
+// however far apart the IDs are.
+func defaultCapacity(a, b Node) int {
+	var d uint64
+	if b >= a {
+		d = uint64(b) - uint64(a)
+	} else {
+		d = uint64(a) - uint64(b)
+	}
+	if d > maxDefaultMapCapacity {
+		return maxDefaultMapCapacity
+	}
+	return int(d)
+}
+
+// errNoPath returns the error for a search that ran out of nodes. Only
+// searches between a single start and end return a *NoPathError.
+func errNoPath(starts []Node, ends goals, expanded int) error {
+	if len(starts) != 1 || len(ends) != 1 {
+		return ErrImpossible
+	}
+	return &NoPathError{Start: starts[0], End: ends[0], NodesExpanded: expanded}
+}
+
+// progress estimates how far along a search is from the predicted cost
+// of the node being expanded and that of the start.
+func progress(predictedCost, initialCost float64) float64 {
+	if !(initialCost > 0) || math.IsInf(initialCost, 1) {
+		return 1
+	}
+	return math.Max(0, math.Min(1, 1-predictedCost/initialCost))
+}
+
+func findPathGoals(mp Graph, starts []Node, ends goals, cfg config) ([]Node, float64, error) {
+	state, ni, err := search(mp, starts, ends, cfg)
+	if ni == nil {
+		return nil, 0, err
+	}
+	path, perr := state.pathToNode(ni)
+	if perr != nil {
+		return nil, 0, perr
+	}
+	return path, ni.cost, err
+}
+
+// search runs A* from the starts until one of the ends is removed from
+// the open list and returns the final state and the record of the end
+// node. For partial searches the record of the closest node is returned
+// along with ErrImpossible if no end could be reached.
+func search(mp Graph, starts []Node, ends goals, cfg config) (*state, *OpenNode, error) {
+	mapCapacity := maxDefaultMapCapacity
+	if len(ends) != 0 {
+		mapCapacity = defaultCapacity(starts[0], ends[0])
+	}
+	// Without a hint node records start in small slabs
+	listCapacity, slabSize := defaultListCapacity, 0
+	if sz, ok := mp.(Sizer); ok {
+		if n := sz.ExpectedNodes(); n > 0 {
+			mapCapacity = n
+			listCapacity = n
+			slabSize = n
+		}
+	}
+	dg, dense := mp.(DenseGraph)
+	if dense {
+		mapCapacity = 0
+	}
+	// The open list is ordered by the sum of current cost + heuristic cost
+	state := cfg.state
+	if state == nil {
+		state = newState(mapCapacity)
+	}
+	if len(state.heap) == 0 && cap(state.heap) < listCapacity {
+		state.heap = make([]*OpenNode, 0, listCapacity)
+	}
+	if len(state.slabs) == 0 && slabSize > minSlabSize {
+		state.slabs = append(state.slabs, make([]OpenNode, slabSize))
+	}
+	tc, _ := mp.(TurnCost)
+	dir, _ := mp.(DirectionalGraph)
+	if tc != nil || dir != nil {
+		state.useTurns = true
+		if state.turns == nil {
+			state.turns = make(map[turnKey]*OpenNode, mapCapacity)
+		}
+		// Only with turns can a node be tracked more than once
+		if _, ok := mp.(CacheableHeuristic); ok {
+			state.cacheHeuristics = true
+			if state.heuristics == nil {
+				state.heuristics = make(map[Node]float64, mapCapacity)
+			}
+		}
+	} else if dense {
+		state.setDense(dg.NodeCount())
+	}
+	if cfg.open != nil {
+		state.open = cfg.open
+	}
+	if cfg.heuristic != nil {
+		state.heuristic = cfg.heuristic
+	}
+	if cfg.scale > 0 && cfg.scale != 1 {
+		state.scale = cfg.scale
+	}
+	if cfg.weight > 1 {
+		state.weight = cfg.weight
+	}
+	if cfg.maxCost > 0 {
+		state.maxCost = cfg.maxCost
+	}
+	budget := state.maxCost
+	if cfg.stats != nil {
+		defer func() { *cfg.stats = state.stats }()
+	}
+	if cfg.explored != nil {
+		defer func() {
+			state.each(func(ni *OpenNode) {
+				if ni.index < 0 {
+					// With turn costs a node may be closed once per
+					// neighbor it's entered from.
+					if c, ok := cfg.explored[ni.node]; !ok || ni.cost < c {
+						cfg.explored[ni.node] = ni.cost
+					}
+				}
+			})
+		}()
+	}
+	if cfg.tree != nil {
+		defer func() {
+			// With turn costs a node may have been reached from several
+			// parents so use the cheapest.
+			best := make(map[Node]*OpenNode)
+			state.each(func(ni *OpenNode) {
+				if ni.parent == nil {
+					return
+				}
+				if b := best[ni.node]; b == nil || ni.cost < b.cost {
+					best[ni.node] = ni
+					cfg.tree[ni.node] = ni.parent.node
+				}
+			})
+		}()
+	}
+	if cfg.checkGoal && state.heuristic == nil {
+		for _, end := range ends {
+			h, err := mp.HeuristicCost(end, end)
+			if err != nil {
+				return nil, nil, err
+			}
+			if math.Abs(h) > heuristicTolerance(0) {
+				return nil, nil, ErrBadHeuristic
+			}
+		}
+	}
+	// Seeds include their starts, which are then already known below
+	if cfg.seeds != nil {
+		if err := state.addSeeds(mp, ends, cfg.seeds); err != nil {
+			return nil, nil, err
+		}
+	}
+	// Add the start nodes to the openlist. Each is the root of its own
+	// tree which pathToNode recognizes by the missing parent.
+	gen, _ := mp.(GenerationDebug)
+	initialCost := math.Inf(1)
+	for _, start := range starts {
+		// A start is already known when a search is resumed
+		if ni := state.lookup(start, nil); ni != nil {
+			initialCost = math.Min(initialCost, ni.predictedCost)
+			continue
+		}
+		pCost, err := state.heuristicCost(mp, ends, start)
+		if err != nil {
+			return nil, nil, err
+		}
+		if math.IsInf(pCost, 1) {
+			continue
+		}
+		initialCost = math.Min(initialCost, pCost)
+		ni := state.newNodeInfo()
+		*ni = OpenNode{
+			node:          start,
+			parent:        nil,
+			cost:          0.0,
+			predictedCost: pCost,
+		}
+		state.addNodeInfo(ni)
+		state.stats.NodesGenerated++
+		if gen != nil {
+			gen.GeneratedNode(start, -1, 0, pCost)
+		}
+	}
+
+	// A nil done channel means the context can never be cancelled so
+	// there's no need to check it.
+	ctx := cfg.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	done := ctx.Done()
+	popped := 0
+
+	// The expanded node closest to the goal for partial paths
+	var closest *OpenNode
+	// Whether an edge was skipped for making a path too long
+	tooLong := false
+
+	dbg, _ := mp.(Debug)
+	if cfg.debug != nil {
+		dbg = cfg.debug
+	}
+	debugSkip := 0 // expanded nodes to leave out before the next call to dbg
+	pp, _ := mp.(PossiblePath)
+	prog, _ := mp.(Progress)
+	_, dedupe := mp.(DuplicateEdges)
+	lazy, _ := mp.(ExpensiveHeuristic)
+	// Any-angle searches can find cheaper paths to closed nodes, and
+	// nodes dropped from a beam look closed but may still be reached
+	// more cheaply
+	_, consistent := mp.(ConsistentHeuristic)
+	consistent = consistent && cfg.los == nil && cfg.beam == 0
+	td, _ := mp.(TimeDependentGraph)
+	valid, _ := mp.(Validator)
+	nc, _ := mp.(NodeCost)
+
+	// The number of paths of the cheapest cost found to each open node,
+	// if there's more than one, to choose between them with cfg.rand
+	var ties map[*OpenNode]int
+	if cfg.rand != nil {
+		ties = make(map[*OpenNode]int)
+	}
+
+	var greedyDeadline time.Time
+	if cfg.greedyAfterTime > 0 {
+		greedyDeadline = time.Now().Add(cfg.greedyAfterTime)
+	}
 
 	edgeSlice := make([]Edge, 0, 8)
+	var uniqueSlice []Edge
 	for {
+		if (done != nil || cfg.stop != nil) && popped&(cancelCheckInterval-1) == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, nil, err
+			}
+			select {
+			case <-cfg.stop:
+				return nil, nil, ErrCancelled
+			default:
+			}
+		}
+
+		// A custom open list has an order of its own
+		if !state.greedy && state.open == nil &&
+			(cfg.greedyAfterNodes > 0 && popped >= cfg.greedyAfterNodes ||
+				!greedyDeadline.IsZero() && popped&(cancelCheckInterval-1) == 0 && time.Now().After(greedyDeadline)) {
+			state.setGreedy()
+		}
+
+		// The budget is checked before popping so a search resumed
+		// with the same state doesn't lose a node.
+		if cfg.maxNodes > 0 && popped == cfg.maxNodes && state.openLen() > 0 {
+			return nil, nil, ErrBudgetExceeded
+		}
 		current := state.popBest()
 		if current == nil {
-			return nil, ErrImpossible
+			err := errNoPath(starts, ends, state.stats.NodesExpanded)
+			if tooLong {
+				err = ErrPathTooLong
+			}
+			if closest != nil {
+				return state, closest, err
+			}
+			return nil, nil, err
 		}
-		if current.node == end {
-			// If we reached the end node then we know the optimal path. Traverse
-			// it (backwards) and return an array of node IDs.
-			return state.pathToNode(current), nil
+		if current.estimated {
+			// Only now is the heuristic worth working out. If it's more
+			// than the estimate the node goes back in the open list.
+			current.estimated = false
+			h, err := state.heuristicCost(mp, ends, current.node)
+			if err != nil {
+				return nil, nil, err
+			}
+			if math.IsInf(h, 1) {
+				continue
+			}
+			if h > current.predictedCost {
+				current.predictedCost = h
+				state.updateNodeInfo(current)
+				continue
+			}
+			current.predictedCost = h
+		}
+		popped++
+		if ends.contains(current.node) || (cfg.isGoal != nil && cfg.isGoal(current.node)) {
+			// If we reached the end node then we know the optimal path,
+			// unless the search turned greedy.
+			if state.greedy {
+				return state, current, ErrGreedyPath
+			}
+			return state, current, nil
+		}
+		if cfg.tolerance > 0 && current.predictedCost <= cfg.tolerance {
+			return state, current, nil
 		}
 		if current.cost >= state.maxCost {
 			continue
 		}
-		if dbg, ok := mp.(Debug); ok {
-			dbg.VisitedNode(current.node, current.parent, float64(current.cost), float64(current.predictedCost))
+		if dbg != nil {
+			if debugSkip == 0 {
+				dbg.VisitedNode(current.node, current.parentNode(), current.cost, current.predictedCost)
+				debugSkip = max(cfg.debugEvery, 1)
+			}
+			debugSkip--
+		}
+		state.stats.NodesExpanded++
+		if prog != nil && state.stats.NodesExpanded%progressInterval == 0 {
+			prog.Progress(progress(current.predictedCost, initialCost))
+		}
+		if cfg.partial && (closest == nil || current.predictedCost < closest.predictedCost ||
+			(current.predictedCost == closest.predictedCost && current.cost < closest.cost)) {
+			closest = current
+		}
+		var (
+			neighbors []Edge
+			err       error
+		)
+		edges := edgeSlice[:0]
+		if cfg.copyEdges {
+			edges = nil
+		}
+		if dir != nil {
+			neighbors, err = dir.NeighborsFrom(current.node, current.parentNode(), edges)
+		} else {
+			neighbors, err = mp.Neighbors(current.node, edges)
 		}
-		neighbors, err := mp.Neighbors(current.node, edgeSlice[:0])
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		if dedupe {
+			uniqueSlice = cheapestEdges(uniqueSlice[:0], neighbors)
+			neighbors = uniqueSlice
 		}
 		for _, edge := range neighbors {
-			// Don't try go backwards
-			if edge.Node == current.parent {
+			if valid != nil && !valid.Valid(edge.Node) {
+				state.stats.InvalidEdges++
 				continue
 			}
-
+			if cfg.blockedNodes[edge.Node] || cfg.blockedEdges[[2]Node{current.node, edge.Node}] {
+				continue
+			}
+			// Also catches NaN
+			if td != nil {
+				edge.Cost = td.EdgeCostAt(current.node, edge.Node, current.cost)
+			}
+			if !(edge.Cost >= 0) {
+				return nil, nil, &InvalidCostError{From: current.node, To: edge.Node, Cost: edge.Cost}
+			}
+			// A self-loop can't be part of an optimal path
+			if edge.Node == current.node {
+				continue
+			}
+			var ni *OpenNode
+			if consistent {
+				// A closed node already has its cheapest path so skip
+				// it before working out the cost of the edge.
+				if ni = state.lookup(edge.Node, current); ni != nil && ni.index < 0 {
+					continue
+				}
+			}
 			// Cost for the neighbor node is the current cost plus the
 			// cost to get to that node.
-			cost := current.cost + float32(edge.Cost)
+			parent := current
+			cost := current.cost + edge.Cost
+			if cfg.los != nil {
+				// For any-angle paths skip the current node and connect
+				// straight to its parent if it's visible.
+				if gp := current.parent; gp != nil && gp.node != edge.Node && cfg.los.LineOfSight(gp.node, edge.Node) {
+					d, err := mp.HeuristicCost(gp.node, edge.Node)
+					if err != nil {
+						return nil, nil, err
+					}
+					parent = gp
+					cost = gp.cost + d
+				}
+			}
+			if tc != nil && parent.parent != nil {
+				cost += tc.TurnCost(parent.parent.node, parent.node, edge.Node)
+			}
+			if nc != nil {
+				c := nc.NodeCost(edge.Node)
+				if !(c >= 0) {
+					return nil, nil, &InvalidCostError{From: current.node, To: edge.Node, Cost: c}
+				}
+				cost += c
+			}
+			if cost >= budget {
+				continue
+			}
+			if cfg.maxLength > 0 && parent.length >= cfg.maxLength {
+				tooLong = true
+				continue
+			}
 
-			ni := state.info[edge.Node]
+			if !consistent {
+				ni = state.lookup(edge.Node, parent)
+			}
 			if ni == nil {
 				// We haven't seen this node so add it to the open list.
-				pCost, err := mp.HeuristicCost(edge.Node, end)
-				if err != nil {
-					return nil, err
+				var pCost float64
+				if lazy != nil {
+					// By consistency the heuristic drops by at most the
+					// cost of the step from the parent.
+					pCost = math.Max(parent.predictedCost-(cost-parent.cost), state.lowerBound(lazy, ends, edge.Node))
+				} else {
+					pCost, err = state.heuristicCost(mp, ends, edge.Node)
+					if err != nil {
+						return nil, nil, err
+					}
+					if math.IsInf(pCost, 1) {
+						// The end can't be reached from this node
+						continue
+					}
 				}
-				ni = &nodeInfo{
+				ni = state.newNodeInfo()
+				*ni = OpenNode{
 					node:          edge.Node,
-					parent:        current.node,
+					parent:        parent,
 					cost:          cost,
-					predictedCost: float32(pCost),
+					predictedCost: pCost,
+					length:        parent.length + 1,
+					estimated:     lazy != nil,
 				}
 				state.addNodeInfo(ni)
+				state.stats.NodesGenerated++
+				if gen != nil {
+					gen.GeneratedNode(edge.Node, parent.node, cost, pCost)
+				}
 			} else if cost < ni.cost {
 				// We've seen this node and the current path is cheaper
 				// so update the changed info and add it to the open list
 				// (replacing if necessary).
-				ni.parent = current.node
+				ni.parent = parent
 				ni.cost = cost
-				if ni.index >= 0 {
-					state.updateNodeInfo(ni)
-				} else {
-					state.addNodeInfo(ni)
+				ni.length = parent.length + 1
+				delete(ties, ni)
+				state.stats.CostUpdates++
+				state.updateNodeInfo(ni)
+			} else {
+				// The children of an open node haven't been generated so
+				// it can switch to another path of the same cost. Keeping
+				// the kth path found with probability 1/k chooses each
+				// one with the same probability.
+				if cfg.rand != nil && cost == ni.cost && ni.index >= 0 {
+					k := max(ties[ni], 1) + 1
+					ties[ni] = k
+					if cfg.rand.Intn(k) == 0 {
+						ni.parent = parent
+						ni.length = parent.length + 1
+					}
 				}
-			} else if edge.Node == end {
-				if cost < state.maxCost {
-					state.maxCost = cost
-				}
-				if pp, ok := mp.(PossiblePath); ok {
-					path := append(state.pathToNode(current), end)
-					pp.PossiblePath(path, float64(cost))
-				}
-				ni = nil
+				continue
 			}
-			if ni != nil && edge.Node == end {
-				if cost < state.maxCost {
-					state.maxCost = cost
-				}
-				if pp, ok := mp.(PossiblePath); ok {
-					pp.PossiblePath(state.pathToNode(ni), float64(ni.cost))
+			// A new or cheaper path to a goal was found. Nodes that cost
+			// more than it can't lead to a better path.
+			if (ends.contains(edge.Node) || (cfg.isGoal != nil && cfg.isGoal(edge.Node))) && cost < state.maxCost {
+				state.maxCost = cost
+				if pp != nil {
+					path, err := state.pathToNode(ni)
+					if err != nil {
+						return nil, nil, err
+					}
+					pp.PossiblePath(path, cost)
 				}
 			}
 		}
+		if cfg.beam > 0 {
+			state.trim(cfg.beam)
+		}
 	}
 }
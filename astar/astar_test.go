@@ -1,8 +1,14 @@
 package astar
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"math"
+	"math/rand"
+	"slices"
 	"testing"
+	"time"
 )
 
 const (
@@ -15,13 +21,6 @@ type gridMap struct {
 	height int
 }
 
-func abs(i int) int {
-	if i < 0 {
-		i = -i
-	}
-	return i
-}
-
 func (g *gridMap) Neighbors(node Node, edges []Edge) ([]Edge, error) {
 	addNode := func(x, y int, cost float64) {
 		v := g.grid[y*g.width+x]
@@ -60,6 +59,14 @@ func (g *gridMap) Neighbors(node Node, edges []Edge) ([]Edge, error) {
 	return edges, nil
 }
 
+func (g *gridMap) Dimensions() (int, int) {
+	return g.width, g.height
+}
+
+func (g *gridMap) Walkable(x, y int) bool {
+	return g.grid[y*g.width+x] == 0
+}
+
 func (g *gridMap) HeuristicCost(start, end Node) (float64, error) {
 	endY := int(end) / g.width
 	endX := int(end) % g.width
@@ -70,8 +77,10 @@ func (g *gridMap) HeuristicCost(start, end Node) (float64, error) {
 	return math.Sqrt(float64(a*a + b*b)), nil
 }
 
-func TestAstar(t *testing.T) {
-	mp := &gridMap{
+// testGrid returns the 10x10 grid most tests search, with a wall down
+// column 4 that's only open at the bottom.
+func testGrid() *gridMap {
+	return &gridMap{
 		grid: []int{
 			0, 0, 0, 0, 1, 0, 0, 0, 0, 0,
 			0, 1, 1, 0, 1, 0, 0, 0, 0, 0,
@@ -87,6 +96,10 @@ func TestAstar(t *testing.T) {
 		width:  10,
 		height: 10,
 	}
+}
+
+func TestAstar(t *testing.T) {
+	mp := testGrid()
 	path, err := FindPath(mp, Node(5*mp.width), Node(3*mp.width+9))
 	if err != nil {
 		t.Fatal(err)
@@ -141,17 +154,344 @@ func TestImpossible(t *testing.T) {
 		width:  10,
 		height: 10,
 	}
-	_, err := FindPath(mp, Node(5*mp.width), Node(3*mp.width+9))
-	if err != ErrImpossible {
+	start, end := Node(5*mp.width), Node(3*mp.width+9)
+	_, stats, err := FindPathStats(mp, start, end)
+	if !errors.Is(err, ErrImpossible) {
 		t.Fatal("Expected ErrImpossible when no path is possible")
 	}
+	var npe *NoPathError
+	if !errors.As(err, &npe) {
+		t.Fatalf("Expected a *NoPathError instead of %T", err)
+	}
+	if npe.Start != start || npe.End != end || npe.NodesExpanded != stats.NodesExpanded {
+		t.Fatalf("Expected start %d, end %d and %d nodes expanded instead of %+v", start, end, stats.NodesExpanded, npe)
+	}
 }
 
 func BenchmarkFindPath(b *testing.B) {
+	mp := testGrid()
+	for i := 0; i < b.N; i++ {
+		FindPath(mp, Node(5*mp.width), Node(3*mp.width+9))
+	}
+}
+
+func TestFindPathCost(t *testing.T) {
+	mp := testGrid()
+	path, cost, err := FindPathCost(mp, Node(5*mp.width), Node(3*mp.width+9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) != 23 {
+		t.Fatalf("Expected a path length of 23 instead of %d", len(path))
+	}
+	// 15 straight moves and 7 diagonal moves
+	expected := 15 + 7*sqrt2
+	if math.Abs(cost-expected) > 1e-4 {
+		t.Fatalf("Expected a path cost of %f instead of %f", expected, cost)
+	}
+}
+
+func TestFindPathContextCancelled(t *testing.T) {
+	mp := &gridMap{
+		grid:   make([]int, 100*100),
+		width:  100,
+		height: 100,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := FindPathContext(ctx, mp, 0, Node(len(mp.grid)-1))
+	if err != context.Canceled {
+		t.Fatalf("Expected context.Canceled instead of %v", err)
+	}
+	path, err := FindPathContext(context.Background(), mp, 0, Node(len(mp.grid)-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) != 100 {
+		t.Fatalf("Expected a path length of 100 instead of %d", len(path))
+	}
+}
+
+func TestFindPathStop(t *testing.T) {
+	mp := &gridMap{
+		grid:   make([]int, 100*100),
+		width:  100,
+		height: 100,
+	}
+	stop := make(chan struct{})
+	close(stop)
+	if _, err := FindPathStop(mp, 0, Node(len(mp.grid)-1), stop); err != ErrCancelled {
+		t.Fatalf("Expected ErrCancelled instead of %v", err)
+	}
+	path, err := FindPathStop(mp, 0, Node(len(mp.grid)-1), make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) != 100 {
+		t.Fatalf("Expected a path length of 100 instead of %d", len(path))
+	}
+	path, err = FindPathStop(mp, 0, Node(len(mp.grid)-1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) != 100 {
+		t.Fatalf("Expected a path length of 100 instead of %d", len(path))
+	}
+}
+
+func TestFindPathStats(t *testing.T) {
+	mp := testGrid()
+	path, stats, err := FindPathStats(mp, Node(5*mp.width), Node(3*mp.width+9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%+v", stats)
+	if stats.NodesExpanded < len(path)-1 {
+		t.Fatalf("Expected at least %d expanded nodes instead of %d", len(path)-1, stats.NodesExpanded)
+	}
+	if stats.NodesGenerated < stats.NodesExpanded {
+		t.Fatalf("Expected generated nodes (%d) to be at least expanded nodes (%d)", stats.NodesGenerated, stats.NodesExpanded)
+	}
+	if stats.MaxOpenSize == 0 || stats.MaxOpenSize > stats.NodesGenerated {
+		t.Fatalf("Unexpected max open size %d", stats.MaxOpenSize)
+	}
+
+	_, stats, err = FindPathStats(mp, 0, 4)
+	if !errors.Is(err, ErrImpossible) {
+		t.Fatal("Expected ErrImpossible when no path is possible")
+	}
+	if stats.NodesExpanded == 0 {
+		t.Fatal("Expected stats to be filled in when no path is found")
+	}
+}
+
+func TestDijkstra(t *testing.T) {
+	mp := testGrid()
+	start, end := Node(5*mp.width), Node(3*mp.width+9)
+	expected, err := FindPath(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := Dijkstra(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) != len(expected) {
+		t.Fatalf("Expected a path length of %d instead of %d", len(expected), len(path))
+	}
+	if _, err := Dijkstra(mp, start, 4); !errors.Is(err, ErrImpossible) {
+		t.Fatal("Expected ErrImpossible when no path is possible")
+	}
+}
+
+func TestFindPathWeighted(t *testing.T) {
+	// Scattered obstacles where the heuristic is a good guide. Behind a
+	// long wall a heavily weighted search can expand more nodes instead.
+	mp := randomGridMap(rand.New(rand.NewSource(1)), 50, 50, 0.2)
+	start, end := Node(0), Node(len(mp.grid)-1)
+	mp.grid[start] = 0
+	mp.grid[end] = 0
+
+	var stats1, stats2 Stats
+	_, cost1, err := findPath(mp, start, end, config{weight: 1, stats: &stats1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, cost2, err := findPath(mp, start, end, config{weight: 2, stats: &stats2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("weight 1.0: cost %f expanded %d", cost1, stats1.NodesExpanded)
+	t.Logf("weight 2.0: cost %f expanded %d", cost2, stats2.NodesExpanded)
+	if path[0] != start || path[len(path)-1] != end {
+		t.Fatalf("Expected path from %d to %d", start, end)
+	}
+	if cost2 < cost1-1e-4 {
+		t.Fatalf("Weighted cost %f is less than optimal cost %f", cost2, cost1)
+	}
+	if cost2 > 2*cost1 {
+		t.Fatalf("Weighted cost %f exceeds bound of %f", cost2, 2*cost1)
+	}
+	if stats2.NodesExpanded >= stats1.NodesExpanded {
+		t.Fatalf("Expected weighted search to expand fewer nodes (%d >= %d)", stats2.NodesExpanded, stats1.NodesExpanded)
+	}
+
+	path1, err := FindPath(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path2, err := FindPathWeighted(mp, start, end, 1.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path1) != len(path2) {
+		t.Fatalf("Expected weight 1.0 to match FindPath")
+	}
+}
+
+func TestFindPathAny(t *testing.T) {
+	mp := testGrid()
+	start := Node(5 * mp.width)
+	ends := []Node{39, 3, 90}
+	path, index, err := FindPathAny(mp, start, ends)
+	if err != nil {
+		t.Fatal(err)
+	}
+	best := math.Inf(1)
+	bestIndex := -1
+	for i, e := range ends {
+		_, c, err := FindPathCost(mp, start, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c < best {
+			best = c
+			bestIndex = i
+		}
+	}
+	if index != bestIndex {
+		t.Fatalf("Expected end index %d instead of %d", bestIndex, index)
+	}
+	if path[0] != start || path[len(path)-1] != ends[bestIndex] {
+		t.Fatalf("Expected path from %d to %d instead of %v", start, ends[bestIndex], path)
+	}
+
+	// The first of duplicate ends is reported
+	_, index, err = FindPathAny(mp, start, []Node{90, ends[bestIndex], ends[bestIndex]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index != 1 {
+		t.Fatalf("Expected end index 1 instead of %d", index)
+	}
+
+	if _, _, err := FindPathAny(mp, start, nil); !errors.Is(err, ErrImpossible) {
+		t.Fatal("Expected ErrImpossible with no ends")
+	}
+	if _, _, err := FindPathAny(mp, start, []Node{4, 14}); !errors.Is(err, ErrImpossible) {
+		t.Fatal("Expected ErrImpossible when no end is reachable")
+	}
+}
+
+func TestFindPathFromAny(t *testing.T) {
+	mp := testGrid()
+	end := Node(3*mp.width + 9)
+	starts := []Node{50, 5, 80}
+	path, index, err := FindPathFromAny(mp, starts, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index != 1 {
+		t.Fatalf("Expected start index 1 instead of %d", index)
+	}
+	expected, err := FindPath(mp, starts[1], end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) != len(expected) {
+		t.Fatalf("Expected a path length of %d instead of %d", len(expected), len(path))
+	}
+	for i, e := range expected {
+		if path[i] != e {
+			t.Fatalf("Expected node at path index %d to be %d instead of %d", i, e, path[i])
+		}
+	}
+
+	if _, _, err := FindPathFromAny(mp, nil, end); !errors.Is(err, ErrImpossible) {
+		t.Fatal("Expected ErrImpossible with no starts")
+	}
+}
+
+// adjGraph is a directed graph with no heuristic
+type adjGraph map[Node][]Edge
+
+func (g adjGraph) Neighbors(node Node, edges []Edge) ([]Edge, error) {
+	return append(edges, g[node]...), nil
+}
+
+func (g adjGraph) HeuristicCost(start, end Node) (float64, error) {
+	return 0, nil
+}
+
+func (g adjGraph) pathCost(t *testing.T, path []Node) float64 {
+	cost := 0.0
+	for i := 1; i < len(path); i++ {
+		found := false
+		for _, e := range g[path[i-1]] {
+			if e.Node == path[i] {
+				cost += e.Cost
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Fatalf("No edge from %d to %d in path %v", path[i-1], path[i], path)
+		}
+	}
+	return cost
+}
+
+func TestFindKPaths(t *testing.T) {
+	const (
+		c Node = iota + 1
+		d
+		e
+		f
+		g
+		h
+	)
+	mp := adjGraph{
+		c: {{d, 3}, {e, 2}},
+		d: {{f, 4}},
+		e: {{d, 1}, {f, 2}, {g, 3}},
+		f: {{g, 2}, {h, 1}},
+		g: {{h, 2}},
+	}
+	paths, err := FindKPaths(mp, c, h, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 3 {
+		t.Fatalf("Expected 3 paths instead of %d", len(paths))
+	}
+	expected := []float64{5, 7, 8}
+	for i, p := range paths {
+		if cost := mp.pathCost(t, p); cost != expected[i] {
+			t.Fatalf("Expected path %d to cost %f instead of %f (%v)", i, expected[i], cost, p)
+		}
+		seen := make(map[Node]bool)
+		for _, n := range p {
+			if seen[n] {
+				t.Fatalf("Path %v contains a loop", p)
+			}
+			seen[n] = true
+		}
+		for _, q := range paths[:i] {
+			if equalPaths(p, q) {
+				t.Fatalf("Path %v returned more than once", p)
+			}
+		}
+	}
+
+	// There are only 7 loopless paths in the graph
+	paths, err = FindKPaths(mp, c, h, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 7 {
+		t.Fatalf("Expected 7 paths instead of %d", len(paths))
+	}
+
+	if _, err := FindKPaths(mp, h, c, 3); !errors.Is(err, ErrImpossible) {
+		t.Fatal("Expected ErrImpossible when no path is possible")
+	}
+}
+
+func TestFindPathPartial(t *testing.T) {
 	mp := &gridMap{
 		grid: []int{
 			0, 0, 0, 0, 1, 0, 0, 0, 0, 0,
-			0, 1, 1, 0, 1, 0, 0, 0, 0, 0,
+			1, 1, 1, 0, 1, 0, 0, 0, 0, 0,
 			0, 0, 1, 0, 1, 0, 0, 0, 0, 0,
 			0, 0, 1, 0, 1, 0, 0, 0, 0, 0,
 			0, 0, 1, 0, 1, 0, 0, 1, 1, 0,
@@ -164,7 +504,3336 @@ func BenchmarkFindPath(b *testing.B) {
 		width:  10,
 		height: 10,
 	}
+	// The goal is walled off so the closest we can get is the
+	// cell of the enclosed region in the same row.
+	path, cost, err := FindPathPartial(mp, Node(5*mp.width), Node(3*mp.width+9))
+	if !errors.Is(err, ErrImpossible) {
+		t.Fatal("Expected ErrImpossible when no path is possible")
+	}
+	expected := []Node{50, 41, 31}
+	if len(path) != len(expected) {
+		t.Fatalf("Expected partial path %v instead of %v", expected, path)
+	}
+	for i, e := range expected {
+		if path[i] != e {
+			t.Fatalf("Expected partial path %v instead of %v", expected, path)
+		}
+	}
+	if math.Abs(cost-(1+sqrt2)) > 1e-4 {
+		t.Fatalf("Expected a partial cost of %f instead of %f", 1+sqrt2, cost)
+	}
+
+	// Reachable goals return the full path
+	mp.grid[10] = 0
+	path, _, err = FindPathPartial(mp, Node(5*mp.width), Node(3*mp.width+9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path[len(path)-1] != Node(3*mp.width+9) {
+		t.Fatalf("Expected full path instead of %v", path)
+	}
+}
+
+func TestFindPathExplored(t *testing.T) {
+	mp := testGrid()
+	start, end := Node(5*mp.width), Node(3*mp.width+9)
+	path, explored, err := FindPathExplored(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, stats, err := FindPathStats(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(explored) < stats.NodesExpanded {
+		t.Fatalf("Expected at least %d explored nodes instead of %d", stats.NodesExpanded, len(explored))
+	}
+	for _, n := range path {
+		if _, ok := explored[n]; !ok {
+			t.Fatalf("Expected path node %d to be explored", n)
+		}
+		if mp.grid[n] != 0 {
+			t.Fatalf("Explored blocked node %d", n)
+		}
+	}
+	if explored[start] != 0 {
+		t.Fatalf("Expected start cost of 0 instead of %f", explored[start])
+	}
+	if math.Abs(explored[end]-(15+7*sqrt2)) > 1e-4 {
+		t.Fatalf("Expected end cost of %f instead of %f", 15+7*sqrt2, explored[end])
+	}
+}
+
+func TestSearcher(t *testing.T) {
+	mp := testGrid()
+	var s Searcher
+	queries := [][2]Node{{50, 39}, {0, 99}, {50, 4}, {99, 0}, {50, 39}}
+	for _, q := range queries {
+		expected, expectedErr := FindPath(mp, q[0], q[1])
+		path, err := s.FindPath(mp, q[0], q[1])
+		if !sameError(err, expectedErr) {
+			t.Fatalf("Expected error %v instead of %v", expectedErr, err)
+		}
+		if len(path) != len(expected) {
+			t.Fatalf("Expected a path length of %d instead of %d", len(expected), len(path))
+		}
+		for i, e := range expected {
+			if path[i] != e {
+				t.Fatalf("Expected node at path index %d to be %d instead of %d", i, e, path[i])
+			}
+		}
+	}
+}
+
+func BenchmarkSearcher(b *testing.B) {
+	mp := testGrid()
+	var s Searcher
 	for i := 0; i < b.N; i++ {
-		FindPath(mp, Node(5*mp.width), Node(3*mp.width+9))
+		s.FindPath(mp, Node(5*mp.width), Node(3*mp.width+9))
+	}
+}
+
+func BenchmarkFindPathLarge(b *testing.B) {
+	mp := &gridMap{
+		grid:   make([]int, 100*100),
+		width:  100,
+		height: 100,
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		FindPath(mp, 0, Node(len(mp.grid)-1))
+	}
+}
+
+func BenchmarkSearcherLarge(b *testing.B) {
+	mp := &gridMap{
+		grid:   make([]int, 100*100),
+		width:  100,
+		height: 100,
+	}
+	var s Searcher
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s.FindPath(mp, 0, Node(len(mp.grid)-1))
+	}
+}
+
+// sameError reports whether two errors are equal treating all errors
+// that wrap ErrImpossible as the same.
+func sameError(a, b error) bool {
+	if errors.Is(a, ErrImpossible) || errors.Is(b, ErrImpossible) {
+		return errors.Is(a, ErrImpossible) && errors.Is(b, ErrImpossible)
+	}
+	return a == b
+}
+
+// gridPathCost returns the cost of a path on an 8-connected grid
+// verifying that every step is to an adjacent walkable cell.
+func gridPathCost(t testing.TB, mp *gridMap, path []Node) float64 {
+	cost := 0.0
+	for i, n := range path {
+		if mp.grid[n] != 0 {
+			t.Fatalf("Path %v goes through blocked node %d", path, n)
+		}
+		if i == 0 {
+			continue
+		}
+		dx := abs(int(n)%mp.width - int(path[i-1])%mp.width)
+		dy := abs(int(n)/mp.width - int(path[i-1])/mp.width)
+		switch {
+		case dx+dy == 1:
+			cost++
+		case dx == 1 && dy == 1:
+			cost += sqrt2
+		default:
+			t.Fatalf("Path %v has a gap between %d and %d", path, path[i-1], n)
+		}
+	}
+	return cost
+}
+
+func randomGridMap(rnd *rand.Rand, width, height int, density float64) *gridMap {
+	mp := &gridMap{
+		grid:   make([]int, width*height),
+		width:  width,
+		height: height,
+	}
+	for i := range mp.grid {
+		if rnd.Float64() < density {
+			mp.grid[i] = 1
+		}
+	}
+	return mp
+}
+
+func TestFindPathJPS(t *testing.T) {
+	mp := testGrid()
+	path, err := FindPathJPS(mp, Node(5*mp.width), Node(3*mp.width+9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost := gridPathCost(t, mp, path); math.Abs(cost-(15+7*sqrt2)) > 1e-4 {
+		t.Fatalf("Expected a path cost of %f instead of %f", 15+7*sqrt2, cost)
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		mp := randomGridMap(rnd, 30, 20, 0.3)
+		start := Node(rnd.Intn(len(mp.grid)))
+		end := Node(rnd.Intn(len(mp.grid)))
+		mp.grid[start] = 0
+		mp.grid[end] = 0
+		_, expected, expectedErr := FindPathCost(mp, start, end)
+		path, err := FindPathJPS(mp, start, end)
+		if !sameError(err, expectedErr) {
+			t.Fatalf("Expected error %v instead of %v", expectedErr, err)
+		}
+		if err != nil {
+			continue
+		}
+		if path[0] != start || path[len(path)-1] != end {
+			t.Fatalf("Expected path from %d to %d instead of %v", start, end, path)
+		}
+		if cost := gridPathCost(t, mp, path); math.Abs(cost-expected) > 1e-3 {
+			t.Fatalf("Expected a path cost of %f instead of %f", expected, cost)
+		}
+	}
+}
+
+func BenchmarkFindPathJPS(b *testing.B) {
+	mp := randomGridMap(rand.New(rand.NewSource(1)), 200, 200, 0.1)
+	mp.grid[0] = 0
+	mp.grid[len(mp.grid)-1] = 0
+	for i := 0; i < b.N; i++ {
+		if _, err := FindPathJPS(mp, 0, Node(len(mp.grid)-1)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFindPathGrid(b *testing.B) {
+	mp := randomGridMap(rand.New(rand.NewSource(1)), 200, 200, 0.1)
+	mp.grid[0] = 0
+	mp.grid[len(mp.grid)-1] = 0
+	for i := 0; i < b.N; i++ {
+		if _, err := FindPath(mp, 0, Node(len(mp.grid)-1)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// losGridMap is a gridMap with an approximate line of sight test
+type losGridMap struct {
+	*gridMap
+}
+
+func (g losGridMap) LineOfSight(a, b Node) bool {
+	ax, ay := float64(int(a)%g.width), float64(int(a)/g.width)
+	bx, by := float64(int(b)%g.width), float64(int(b)/g.width)
+	steps := int(math.Ceil(math.Max(math.Abs(bx-ax), math.Abs(by-ay)) * 10))
+	for i := 0; i <= steps; i++ {
+		f := float64(i) / float64(steps)
+		x := int(math.Floor(ax + (bx-ax)*f + 0.5))
+		y := int(math.Floor(ay + (by-ay)*f + 0.5))
+		if g.grid[y*g.width+x] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFindPathTheta(t *testing.T) {
+	mp := losGridMap{&gridMap{
+		grid:   make([]int, 10*10),
+		width:  10,
+		height: 10,
+	}}
+	end := Node(3*mp.width + 9)
+	path, err := FindPathTheta(mp, 0, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) != 2 || path[0] != 0 || path[1] != end {
+		t.Fatalf("Expected a straight path on an open grid instead of %v", path)
+	}
+
+	mp = losGridMap{testGrid()}
+	start := Node(5 * mp.width)
+	gridPath, err := FindPath(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err = FindPathTheta(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) >= len(gridPath) {
+		t.Fatalf("Expected fewer than %d nodes instead of %d", len(gridPath), len(path))
+	}
+	if path[0] != start || path[len(path)-1] != end {
+		t.Fatalf("Expected path from %d to %d instead of %v", start, end, path)
+	}
+	for i := 1; i < len(path); i++ {
+		dx := abs(int(path[i])%mp.width - int(path[i-1])%mp.width)
+		dy := abs(int(path[i])/mp.width - int(path[i-1])/mp.width)
+		if (dx > 1 || dy > 1) && !mp.LineOfSight(path[i-1], path[i]) {
+			t.Fatalf("No line of sight between %d and %d in path %v", path[i-1], path[i], path)
+		}
+	}
+}
+
+func TestSmoothPath(t *testing.T) {
+	mp := losGridMap{&gridMap{
+		grid: []int{
+			0, 0, 0, 0, 0,
+			0, 0, 0, 0, 0,
+			1, 1, 1, 1, 0,
+			0, 0, 0, 0, 0,
+			0, 0, 0, 0, 0,
+		},
+		width:  5,
+		height: 5,
+	}}
+	path, err := FindPath(mp, 0, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := append([]Node(nil), path...)
+	smooth := SmoothPath(mp, path)
+	if !equalPaths(path, orig) {
+		t.Fatalf("SmoothPath modified its input: %v", path)
+	}
+	if len(smooth) >= len(path) {
+		t.Fatalf("Expected fewer than %d nodes instead of %v", len(path), smooth)
+	}
+	if smooth[0] != 0 || smooth[len(smooth)-1] != 20 {
+		t.Fatalf("Expected path from 0 to 20 instead of %v", smooth)
+	}
+	for i := 1; i < len(smooth); i++ {
+		if !mp.LineOfSight(smooth[i-1], smooth[i]) {
+			t.Fatalf("No line of sight between %d and %d in path %v", smooth[i-1], smooth[i], smooth)
+		}
+	}
+
+	if p := SmoothPath(mp, []Node{3}); !equalPaths(p, []Node{3}) {
+		t.Fatalf("Expected a single node path to be unchanged instead of %v", p)
+	}
+}
+
+// revGridMap is a gridMap which can enumerate incoming edges. Since
+// grid costs are symmetric these are the same as the outgoing edges
+// except for blocked nodes which can't be entered.
+type revGridMap struct {
+	*gridMap
+}
+
+func (g revGridMap) ReverseNeighbors(node Node, edges []Edge) ([]Edge, error) {
+	if g.grid[node] != 0 {
+		return edges, nil
+	}
+	return g.Neighbors(node, edges)
+}
+
+func TestDStarLite(t *testing.T) {
+	mp := revGridMap{randomGridMap(rand.New(rand.NewSource(1)), 40, 40, 0.25)}
+	start, end := Node(0), Node(len(mp.grid)-1)
+	mp.grid[start] = 0
+	mp.grid[end] = 0
+	ds := NewDStarLite(mp)
+	// Changes before Init are ignored
+	ds.UpdateEdge(start, start+1, math.Inf(1))
+	if _, err := ds.Plan(); err != ErrImpossible {
+		t.Fatalf("Expected ErrImpossible before Init instead of %v", err)
+	}
+	ds.Init(start, end)
+	path, err := ds.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, expected, err := FindPathCost(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost := gridPathCost(t, mp.gridMap, path); math.Abs(cost-expected) > 1e-4 {
+		t.Fatalf("Expected a path cost of %f instead of %f", expected, cost)
+	}
+	initial := ds.Stats().NodesExpanded
+
+	// Block a node near the start of the path as if it was just
+	// discovered by a robot following the path.
+	blocked := path[2]
+	mp.grid[blocked] = 1
+	edges, _ := mp.Neighbors(blocked, nil)
+	for _, e := range edges {
+		ds.UpdateEdge(e.Node, blocked, math.Inf(1))
+	}
+	path, err = ds.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, expected, err = FindPathCost(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost := gridPathCost(t, mp.gridMap, path); math.Abs(cost-expected) > 1e-4 {
+		t.Fatalf("Expected a path cost of %f instead of %f", expected, cost)
+	}
+	replan := ds.Stats().NodesExpanded
+	t.Logf("initial plan expanded %d nodes, replan expanded %d", initial, replan)
+	if replan*4 > initial {
+		t.Fatalf("Expected replanning to expand far fewer than %d nodes instead of %d", initial, replan)
+	}
+
+	// Move along the path and replan from there
+	ds.SetStart(path[3])
+	path2, err := ds.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path2) != len(path)-3 || path2[0] != path[3] {
+		t.Fatalf("Expected the remainder of %v instead of %v", path, path2)
+	}
+
+	// Wall off the goal
+	edges, _ = mp.Neighbors(end, nil)
+	for _, e := range edges {
+		ds.UpdateEdge(e.Node, end, math.Inf(1))
+	}
+	if _, err := ds.Plan(); !errors.Is(err, ErrImpossible) {
+		t.Fatalf("Expected ErrImpossible instead of %v", err)
+	}
+}
+
+func TestFindPathLimited(t *testing.T) {
+	mp := testGrid()
+	start, end := Node(5*mp.width), Node(3*mp.width+9)
+	if _, err := FindPathLimited(mp, start, end, 10); err != ErrBudgetExceeded {
+		t.Fatalf("Expected ErrBudgetExceeded instead of %v", err)
+	}
+	path, err := FindPathLimited(mp, start, end, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path[len(path)-1] != end {
+		t.Fatalf("Expected path to end at %d", end)
+	}
+	if _, err := FindPathLimited(mp, 0, 4, 1000); !errors.Is(err, ErrImpossible) {
+		t.Fatalf("Expected ErrImpossible instead of %v", err)
+	}
+}
+
+func TestFindPathMaxCost(t *testing.T) {
+	mp := testGrid()
+	start, end := Node(5*mp.width), Node(3*mp.width+9)
+	cost := 15 + 7*sqrt2
+	path, err := FindPathMaxCost(mp, start, end, cost+0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path[len(path)-1] != end {
+		t.Fatalf("Expected path to end at %d", end)
+	}
+	if _, err := FindPathMaxCost(mp, start, end, cost-0.1); !errors.Is(err, ErrImpossible) {
+		t.Fatalf("Expected ErrImpossible instead of %v", err)
+	}
+	if _, err := FindPathMaxCost(mp, start, end, 0); !errors.Is(err, ErrImpossible) {
+		t.Fatalf("Expected ErrImpossible instead of %v", err)
+	}
+}
+
+// reversedGridMap returns the neighbors of gridMap in reverse order
+type reversedGridMap struct {
+	*gridMap
+}
+
+func (g reversedGridMap) Neighbors(node Node, edges []Edge) ([]Edge, error) {
+	edges, err := g.gridMap.Neighbors(node, edges)
+	for i, j := 0, len(edges)-1; i < j; i, j = i+1, j-1 {
+		edges[i], edges[j] = edges[j], edges[i]
+	}
+	return edges, err
+}
+
+func TestDeterministicTies(t *testing.T) {
+	// An open grid has many paths of equal cost
+	mp := &gridMap{
+		grid:   make([]int, 20*20),
+		width:  20,
+		height: 20,
+	}
+	start, end := Node(2*mp.width+1), Node(15*mp.width+18)
+	expected, err := FindPath(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		for _, g := range []Graph{mp, reversedGridMap{mp}} {
+			path, err := FindPath(g, start, end)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(path) != len(expected) {
+				t.Fatalf("Expected a path length of %d instead of %d", len(expected), len(path))
+			}
+			for i, e := range expected {
+				if path[i] != e {
+					t.Fatalf("Expected node at path index %d to be %d instead of %d", i, e, path[i])
+				}
+			}
+		}
+	}
+}
+
+func TestAsymmetricEdges(t *testing.T) {
+	// The cheapest route goes through node -1 which used to be confused
+	// with the missing parent of the start node and skipped.
+	mp := adjGraph{
+		0:  {{-1, 1}, {2, 10}},
+		-1: {{0, 5}, {2, 1}},
+		2:  {{-1, 3}},
+	}
+	path, cost, err := FindPathCost(mp, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Node{0, -1, 2}
+	if len(path) != len(expected) || path[1] != -1 {
+		t.Fatalf("Expected path %v instead of %v", expected, path)
+	}
+	if cost != 2 {
+		t.Fatalf("Expected a path cost of 2 instead of %f", cost)
+	}
+
+	// Going back the way we came is expensive
+	mp = adjGraph{
+		1: {{2, 1}, {4, 20}},
+		2: {{1, 1}, {3, 1}},
+		3: {{2, 8}, {4, 1}},
+	}
+	path, cost, err = FindPathCost(mp, 1, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost != 3 || mp.pathCost(t, path) != 3 {
+		t.Fatalf("Expected a path cost of 3 instead of %f (%v)", cost, path)
+	}
+}
+
+type possiblePath struct {
+	path []Node
+	cost float64
+}
+
+// recordingGridMap records every call to PossiblePath
+type recordingGridMap struct {
+	*gridMap
+	possible []possiblePath
+}
+
+func (g *recordingGridMap) PossiblePath(path []Node, cost float64) {
+	g.possible = append(g.possible, possiblePath{path, cost})
+}
+
+func TestPossiblePath(t *testing.T) {
+	mp := &recordingGridMap{gridMap: randomGridMap(rand.New(rand.NewSource(2)), 30, 30, 0.3)}
+	start, end := Node(0), Node(len(mp.grid)-1)
+	mp.grid[start] = 0
+	mp.grid[end] = 0
+	path, cost, err := FindPathCost(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mp.possible) == 0 {
+		t.Fatal("Expected PossiblePath to be called")
+	}
+	for i, p := range mp.possible {
+		if i > 0 && p.cost >= mp.possible[i-1].cost {
+			t.Fatalf("Expected possible path %d to cost less than %f instead of %f", i, mp.possible[i-1].cost, p.cost)
+		}
+		if p.path[0] != start || p.path[len(p.path)-1] != end {
+			t.Fatalf("Expected possible path from %d to %d instead of %v", start, end, p.path)
+		}
+		if c := gridPathCost(t, mp.gridMap, p.path); math.Abs(c-p.cost) > 1e-3 {
+			t.Fatalf("Possible path %d reported a cost of %f but costs %f", i, p.cost, c)
+		}
+	}
+	last := mp.possible[len(mp.possible)-1]
+	if last.cost != cost || len(last.path) != len(path) {
+		t.Fatalf("Expected the last possible path to be the returned path")
+	}
+}
+
+// denseGridMap is a gridMap that implements DenseGraph
+type denseGridMap struct {
+	*gridMap
+}
+
+func (g denseGridMap) NodeCount() int {
+	return len(g.grid)
+}
+
+func TestDenseGraph(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+	var s Searcher
+	for i := 0; i < 50; i++ {
+		mp := randomGridMap(rnd, 30, 20, 0.3)
+		start := Node(rnd.Intn(len(mp.grid)))
+		end := Node(rnd.Intn(len(mp.grid)))
+		mp.grid[start] = 0
+		mp.grid[end] = 0
+		expected, expectedErr := FindPath(mp, start, end)
+		for _, find := range []func(Graph, Node, Node) ([]Node, error){FindPath, s.FindPath} {
+			path, err := find(denseGridMap{mp}, start, end)
+			if err != expectedErr {
+				t.Fatalf("Expected error %v instead of %v", expectedErr, err)
+			}
+			if len(path) != len(expected) {
+				t.Fatalf("Expected a path length of %d instead of %d", len(expected), len(path))
+			}
+			for i, e := range expected {
+				if path[i] != e {
+					t.Fatalf("Expected node at path index %d to be %d instead of %d", i, e, path[i])
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkFindPathDense(b *testing.B) {
+	mp := randomGridMap(rand.New(rand.NewSource(1)), 200, 200, 0.1)
+	mp.grid[0] = 0
+	mp.grid[len(mp.grid)-1] = 0
+	dmp := denseGridMap{mp}
+	for i := 0; i < b.N; i++ {
+		if _, err := FindPath(dmp, 0, Node(len(mp.grid)-1)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// sizedGridMap is a gridMap that implements Sizer
+type sizedGridMap struct {
+	*gridMap
+	expected int
+}
+
+func (g sizedGridMap) ExpectedNodes() int {
+	return g.expected
+}
+
+func TestSizer(t *testing.T) {
+	mp := randomGridMap(rand.New(rand.NewSource(1)), 50, 50, 0.2)
+	mp.grid[0] = 0
+	mp.grid[len(mp.grid)-1] = 0
+	expected, expectedErr := FindPath(mp, 0, Node(len(mp.grid)-1))
+	path, err := FindPath(sizedGridMap{mp, len(mp.grid)}, 0, Node(len(mp.grid)-1))
+	if err != expectedErr || len(path) != len(expected) {
+		t.Fatalf("Expected sized graph to match FindPath")
+	}
+}
+
+func BenchmarkFindPathUnsized(b *testing.B) {
+	mp := randomGridMap(rand.New(rand.NewSource(1)), 200, 200, 0.1)
+	mp.grid[199] = 0
+	mp.grid[len(mp.grid)-200] = 0
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		// A start and end close in ID gives a poor capacity estimate
+		if _, err := FindPath(mp, 199, Node(len(mp.grid)-200)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFindPathSized(b *testing.B) {
+	mp := randomGridMap(rand.New(rand.NewSource(1)), 200, 200, 0.1)
+	mp.grid[199] = 0
+	mp.grid[len(mp.grid)-200] = 0
+	_, stats, err := FindPathStats(mp, 199, Node(len(mp.grid)-200))
+	if err != nil {
+		b.Fatal(err)
+	}
+	smp := sizedGridMap{mp, stats.NodesGenerated}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := FindPath(smp, 199, Node(len(mp.grid)-200)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestGraphFunc(t *testing.T) {
+	// A line of nodes where each node connects to the next two
+	mp := NewGraphFunc(func(node Node, edges []Edge) ([]Edge, error) {
+		return append(edges, Edge{node + 1, 1}, Edge{node + 2, 1.5}), nil
+	}, func(start, end Node) (float64, error) {
+		return float64(end-start) * 0.75, nil
+	})
+	path, cost, err := FindPathCost(mp, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) != 6 || cost != 7.5 {
+		t.Fatalf("Expected 5 steps costing 7.5 instead of %v costing %f", path, cost)
+	}
+
+	mp = NewGraphFunc(func(node Node, edges []Edge) ([]Edge, error) {
+		if node >= 5 {
+			return edges, nil
+		}
+		return append(edges, Edge{node + 1, 1}), nil
+	}, nil)
+	if _, err := FindPath(mp, 0, 10); !errors.Is(err, ErrImpossible) {
+		t.Fatalf("Expected ErrImpossible instead of %v", err)
+	}
+}
+
+func TestAdjacencyGraph(t *testing.T) {
+	// Nodes are points on a line so the distance between IDs is an
+	// admissible heuristic.
+	mp := NewAdjacencyGraph(map[Node][]Edge{
+		0: {{1, 1}, {3, 5}},
+		1: {{2, 1}},
+		2: {{3, 1}, {9, 1}},
+	}, func(a, b Node) float64 {
+		return math.Abs(float64(b - a))
+	})
+	path, cost, err := FindPathCost(mp, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) != 4 || cost != 3 {
+		t.Fatalf("Expected a path of 4 nodes costing 3 instead of %v costing %f", path, cost)
+	}
+	if _, err := FindPath(mp, 3, 0); !errors.Is(err, ErrImpossible) {
+		t.Fatalf("Expected ErrImpossible instead of %v", err)
+	}
+}
+
+func TestMatrixGraph(t *testing.T) {
+	inf := math.Inf(1)
+	mp, err := NewMatrixGraph([][]float64{
+		{0, 4, 1, inf},
+		{inf, 0, inf, 1},
+		{inf, 2, 0, 5},
+		{inf, inf, inf, 0},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, cost, err := FindPathCost(mp, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Node{0, 2, 1, 3}
+	if len(path) != len(expected) || cost != 4 {
+		t.Fatalf("Expected path %v costing 4 instead of %v costing %f", expected, path, cost)
+	}
+	for i, e := range expected {
+		if path[i] != e {
+			t.Fatalf("Expected path %v instead of %v", expected, path)
+		}
+	}
+	if _, err := FindPath(mp, 3, 0); !errors.Is(err, ErrImpossible) {
+		t.Fatalf("Expected ErrImpossible instead of %v", err)
+	}
+
+	if _, err := NewMatrixGraph([][]float64{{0, 1}, {1}}, nil); err == nil {
+		t.Fatal("Expected an error for a ragged matrix")
+	}
+	if _, err := NewMatrixGraph([][]float64{{0, 1, 2}, {1, 0, 2}}, nil); err == nil {
+		t.Fatal("Expected an error for a non-square matrix")
+	}
+}
+
+func TestFindPathWaypoints(t *testing.T) {
+	mp := testGrid()
+	waypoints := []Node{50, 3, 90, 39}
+	path, cost, err := FindPathWaypoints(mp, waypoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := gridPathCost(t, mp, path); math.Abs(c-cost) > 1e-4 {
+		t.Fatalf("Expected a path cost of %f instead of %f", c, cost)
+	}
+	w := 0
+	for _, n := range path {
+		if w < len(waypoints) && n == waypoints[w] {
+			w++
+		}
+	}
+	if w != len(waypoints) || path[len(path)-1] != waypoints[len(waypoints)-1] {
+		t.Fatalf("Expected path %v to visit %v in order", path, waypoints)
+	}
+
+	_, _, err = FindPathWaypoints(mp, []Node{50, 3, 4, 39})
+	var legErr *LegError
+	if !errors.As(err, &legErr) {
+		t.Fatalf("Expected a LegError instead of %v", err)
+	}
+	if legErr.Leg != 1 || legErr.From != 3 || legErr.To != 4 {
+		t.Fatalf("Expected leg 1 from 3 to 4 to fail instead of %+v", legErr)
+	}
+	if !errors.Is(err, ErrImpossible) {
+		t.Fatal("Expected error to wrap ErrImpossible")
+	}
+}
+
+func TestFindPathSeq(t *testing.T) {
+	mp := testGrid()
+	start, end := Node(5*mp.width), Node(3*mp.width+9)
+	expected, err := FindPath(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err := FindPathSeq(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pass := 0; pass < 2; pass++ {
+		i := 0
+		for n := range seq {
+			if i >= len(expected) || n != expected[i] {
+				t.Fatalf("Unexpected node %d at path index %d", n, i)
+			}
+			i++
+		}
+		if i != len(expected) {
+			t.Fatalf("Expected a path length of %d instead of %d", len(expected), i)
+		}
+	}
+	for n := range seq {
+		if n != start {
+			t.Fatalf("Expected first node to be %d instead of %d", start, n)
+		}
+		break
+	}
+
+	if _, err := FindPathSeq(mp, start, 4); !errors.Is(err, ErrImpossible) {
+		t.Fatalf("Expected ErrImpossible instead of %v", err)
+	}
+}
+
+func TestFindPathInto(t *testing.T) {
+	mp := testGrid()
+	start, end := Node(5*mp.width), Node(3*mp.width+9)
+	expected, err := FindPath(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]Node, 5, 64)
+	path, err := FindPathInto(mp, start, end, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if &path[0] != &buf[:1][0] {
+		t.Fatal("Expected path to reuse the buffer")
+	}
+	if len(path) != len(expected) {
+		t.Fatalf("Expected a path length of %d instead of %d", len(expected), len(path))
+	}
+	for i, e := range expected {
+		if path[i] != e {
+			t.Fatalf("Expected node at path index %d to be %d instead of %d", i, e, path[i])
+		}
+	}
+
+	// Too small a buffer is grown
+	path, err = FindPathInto(mp, start, end, make([]Node, 0, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) != len(expected) {
+		t.Fatalf("Expected a path length of %d instead of %d", len(expected), len(path))
+	}
+
+	path, err = FindPathInto(mp, start, 4, buf)
+	if !errors.Is(err, ErrImpossible) || len(path) != 0 {
+		t.Fatalf("Expected an empty path and ErrImpossible instead of %v, %v", path, err)
+	}
+}
+
+type pqItem struct {
+	priority float64
+}
+
+func (it *pqItem) Priority() float64 {
+	return it.priority
+}
+
+func TestPriorityQueue(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	var pq PriorityQueue
+	items := make([]*pqItem, 100)
+	for i := range items {
+		items[i] = &pqItem{rnd.Float64()}
+		pq.Push(items[i])
+	}
+	if pq.Len() != len(items) {
+		t.Fatalf("Expected a length of %d instead of %d", len(items), pq.Len())
+	}
+	// Decrease some priorities and increase others
+	for i := 0; i+1 < len(items); i += 3 {
+		items[i].priority = rnd.Float64() - 0.5
+		pq.Update(items[i])
+		items[i+1].priority += 1
+		pq.Update(items[i+1])
+	}
+	last := math.Inf(-1)
+	for pq.Len() > 0 {
+		it := pq.Pop().(*pqItem)
+		if it.priority < last {
+			t.Fatalf("Popped priority %f after %f", it.priority, last)
+		}
+		if pq.Contains(it) {
+			t.Fatal("Popped item still in the queue")
+		}
+		last = it.priority
+	}
+	if pq.Pop() != nil {
+		t.Fatal("Expected nil from an empty queue")
+	}
+	// Updating an item that isn't queued does nothing
+	pq.Update(items[0])
+	if pq.Len() != 0 {
+		t.Fatal("Expected Update to not add an item")
+	}
+}
+
+// scaledGridMap multiplies the heuristic of a gridMap
+type scaledGridMap struct {
+	*gridMap
+	scale float64
+}
+
+func (g scaledGridMap) HeuristicCost(start, end Node) (float64, error) {
+	h, err := g.gridMap.HeuristicCost(start, end)
+	return h * g.scale, err
+}
+
+func TestCheckHeuristic(t *testing.T) {
+	mp := &gridMap{
+		grid:   make([]int, 10*10),
+		width:  10,
+		height: 10,
+	}
+	nodes := make([]Node, len(mp.grid))
+	for i := range nodes {
+		nodes[i] = Node(i)
+	}
+	goal := Node(55)
+	violations, err := CheckHeuristic(mp, nodes, goal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 0 {
+		t.Fatalf("Expected no violations for Euclidean distance instead of %+v", violations[0])
+	}
+
+	violations, err = CheckHeuristic(scaledGridMap{mp, 2}, nodes, goal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) == 0 {
+		t.Fatal("Expected violations for an overestimating heuristic")
+	}
+	for _, v := range violations {
+		if v.Excess <= 0 || math.Abs(v.H-(v.EdgeCost+v.NeighborH)-v.Excess) > 1e-9 {
+			t.Fatalf("Inconsistent violation %+v", v)
+		}
+	}
+
+	offset := NewGraphFunc(mp.Neighbors, func(start, end Node) (float64, error) {
+		return 1, nil
+	})
+	violations, err = CheckHeuristic(offset, nil, goal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 1 || violations[0].Node != goal || violations[0].Excess != 1 {
+		t.Fatalf("Expected a violation for the goal instead of %+v", violations)
+	}
+}
+
+// turnGraph adds a penalty to turns through an adjGraph
+type turnGraph struct {
+	adjGraph
+	penalty func(from, via, to Node) float64
+}
+
+func (g turnGraph) TurnCost(from, via, to Node) float64 {
+	return g.penalty(from, via, to)
+}
+
+func TestTurnCost(t *testing.T) {
+	mp := adjGraph{
+		0: {{1, 1}, {2, 2}},
+		1: {{3, 1}},
+		2: {{3, 1}},
+	}
+	path, err := FindPath(mp, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, []Node{0, 1, 3}) {
+		t.Fatalf("Expected path [0 1 3] without turn costs instead of %v", path)
+	}
+	tg := turnGraph{mp, func(from, via, to Node) float64 {
+		if via == 1 {
+			return 5
+		}
+		return 0
+	}}
+	path, cost, err := FindPathCost(tg, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, []Node{0, 2, 3}) || cost != 3 {
+		t.Fatalf("Expected path [0 2 3] with cost 3 instead of %v with cost %f", path, cost)
+	}
+
+	// Node 4 is reached most cheaply from 1 but the turn from 1 through 4
+	// to the goal is expensive, so 4 must also be tracked when entered from 2.
+	mp = adjGraph{
+		0: {{1, 1}, {2, 2}},
+		1: {{4, 1}},
+		2: {{4, 1}},
+		4: {{3, 1}},
+	}
+	tg = turnGraph{mp, func(from, via, to Node) float64 {
+		if from == 1 && via == 4 && to == 3 {
+			return 10
+		}
+		return 0
+	}}
+	path, cost, err = FindPathCost(tg, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, []Node{0, 2, 4, 3}) || cost != 4 {
+		t.Fatalf("Expected path [0 2 4 3] with cost 4 instead of %v with cost %f", path, cost)
+	}
+
+	// A searcher must not keep using turn states for a plain graph.
+	var s Searcher
+	if _, err := s.FindPath(tg, 0, 3); err != nil {
+		t.Fatal(err)
+	}
+	path, err = s.FindPath(mp, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, []Node{0, 1, 4, 3}) {
+		t.Fatalf("Expected path [0 1 4 3] instead of %v", path)
+	}
+}
+
+func TestCostField(t *testing.T) {
+	mp := revGridMap{randomGridMap(rand.New(rand.NewSource(2)), 20, 20, 0.25)}
+	goal := Node(10*mp.width + 10)
+	mp.grid[goal] = 0
+	field, err := CostField(mp, goal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := field[goal]; !ok || c != 0 {
+		t.Fatalf("Expected the goal to have cost 0 instead of %f", c)
+	}
+	for i, v := range mp.grid {
+		node := Node(i)
+		c, ok := field[node]
+		if v != 0 {
+			if ok {
+				t.Fatalf("Blocked node %d has cost %f", node, c)
+			}
+			continue
+		}
+		_, expected, err := FindPathCost(mp, node, goal)
+		if errors.Is(err, ErrImpossible) {
+			if ok {
+				t.Fatalf("Node %d can't reach the goal but has cost %f", node, c)
+			}
+			continue
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			t.Fatalf("Node %d is missing from the cost field", node)
+		}
+		if math.Abs(c-expected) > 1e-3 {
+			t.Fatalf("Expected cost %f for node %d instead of %f", expected, node, c)
+		}
+	}
+}
+
+// progressGridMap records the progress reported by a search
+type progressGridMap struct {
+	*gridMap
+	fractions *[]float64
+}
+
+func (g progressGridMap) Progress(fraction float64) {
+	*g.fractions = append(*g.fractions, fraction)
+}
+
+func TestProgress(t *testing.T) {
+	mp := randomGridMap(rand.New(rand.NewSource(1)), 100, 100, 0.2)
+	start, end := Node(0), Node(len(mp.grid)-1)
+	mp.grid[start] = 0
+	mp.grid[end] = 0
+	expected, err := FindPath(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fractions []float64
+	path, err := FindPath(progressGridMap{mp, &fractions}, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, expected) {
+		t.Fatalf("Expected path %v instead of %v", expected, path)
+	}
+	if len(fractions) == 0 {
+		t.Fatal("Expected progress to be reported")
+	}
+	for _, f := range fractions {
+		if f < 0 || f > 1 {
+			t.Fatalf("Progress %f is outside of [0, 1]", f)
+		}
+	}
+	if last := fractions[len(fractions)-1]; last <= fractions[0] {
+		t.Fatalf("Expected progress to increase from %f instead of ending at %f", fractions[0], last)
+	}
+}
+
+func TestFindPathNear(t *testing.T) {
+	mp := randomGridMap(rand.New(rand.NewSource(1)), 40, 40, 0.25)
+	start, end := Node(0), Node(len(mp.grid)-1)
+	mp.grid[start] = 0
+	mp.grid[end] = 0
+	expected, err := FindPath(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := FindPathNear(mp, start, end, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, expected) {
+		t.Fatalf("Expected path %v with no tolerance instead of %v", expected, path)
+	}
+
+	path, err = FindPathNear(mp, start, end, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) == 0 || path[0] != start || len(path) > len(expected) {
+		t.Fatalf("Expected a path from %d no longer than %v instead of %v", start, expected, path)
+	}
+	last := path[len(path)-1]
+	if h, _ := mp.HeuristicCost(last, end); h > 2 {
+		t.Fatalf("Expected the path to end within 2 of %d instead of %f at %d", end, h, last)
+	}
+
+	// A goal that can't be entered can still be approached
+	mp.grid[end] = 1
+	mp.grid[end-1] = 0
+	mp.grid[end-Node(mp.width)] = 0
+	if _, err := FindPath(mp, start, end); !errors.Is(err, ErrImpossible) {
+		t.Fatalf("Expected ErrImpossible for a blocked goal instead of %v", err)
+	}
+	if _, err := FindPathNear(mp, start, end, 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindPathSMA(t *testing.T) {
+	mp := randomGridMap(rand.New(rand.NewSource(1)), 20, 20, 0.25)
+	start, end := Node(0), Node(len(mp.grid)-1)
+	mp.grid[start] = 0
+	mp.grid[end] = 0
+	_, expected, err := FindPathCost(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, maxNodes := range []int{100000, 500, 100, 40} {
+		path, err := FindPathSMA(mp, start, end, maxNodes)
+		if err != nil {
+			t.Fatalf("maxNodes %d: %s", maxNodes, err)
+		}
+		if path[0] != start || path[len(path)-1] != end {
+			t.Fatalf("Expected path from %d to %d instead of %v", start, end, path)
+		}
+		cost := gridPathCost(t, mp, path)
+		if math.Abs(cost-expected) > 1e-3 {
+			t.Fatalf("maxNodes %d: expected cost %f instead of %f for %v", maxNodes, expected, cost, path)
+		}
+	}
+
+	// The path has 4 nodes so it can't be found with less memory
+	g := adjGraph{
+		0: {{1, 1}, {4, 1}},
+		1: {{2, 1}, {0, 1}},
+		2: {{3, 1}},
+		4: {{4, 1}},
+	}
+	path, err := FindPathSMA(g, 0, 3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, []Node{0, 1, 2, 3}) {
+		t.Fatalf("Expected path [0 1 2 3] instead of %v", path)
+	}
+	if _, err := FindPathSMA(g, 0, 3, 3); !errors.Is(err, ErrImpossible) {
+		t.Fatalf("Expected ErrImpossible instead of %v", err)
+	}
+	if _, err := FindPathSMA(g, 0, 5, 10); !errors.Is(err, ErrImpossible) {
+		t.Fatalf("Expected ErrImpossible for an unreachable node instead of %v", err)
+	}
+
+	// The end is walled off in an open grid with many paths of equal cost,
+	// which is quick to search with room in memory for every cell
+	for _, size := range []int{6, 12} {
+		mp := randomGridMap(rand.New(rand.NewSource(1)), size, size, 0)
+		end := Node(len(mp.grid) - 1)
+		for _, n := range []Node{end - 1, end - Node(size), end - Node(size) - 1} {
+			mp.grid[n] = 1
+		}
+		for _, maxNodes := range []int{100000, 2 * size * size} {
+			if _, err := FindPathSMA(mp, 0, end, maxNodes); !errors.Is(err, ErrImpossible) {
+				t.Fatalf("%dx%d, maxNodes %d: expected ErrImpossible instead of %v", size, size, maxNodes, err)
+			}
+		}
+	}
+}
+
+// turnGridMap penalizes changes of direction on a gridMap and counts
+// calls to HeuristicCost
+type turnGridMap struct {
+	*gridMap
+	calls map[Node]int
+}
+
+func (g turnGridMap) HeuristicCost(start, end Node) (float64, error) {
+	g.calls[start]++
+	return g.gridMap.HeuristicCost(start, end)
+}
+
+func (g turnGridMap) TurnCost(from, via, to Node) float64 {
+	if via-from != to-via {
+		return 0.5
+	}
+	return 0
+}
+
+type cachedTurnGridMap struct {
+	turnGridMap
+}
+
+func (cachedTurnGridMap) CacheableHeuristic() {}
+
+func TestCacheableHeuristic(t *testing.T) {
+	mp := randomGridMap(rand.New(rand.NewSource(1)), 20, 20, 0.2)
+	start, end := Node(0), Node(len(mp.grid)-1)
+	mp.grid[start] = 0
+	mp.grid[end] = 0
+
+	uncached := turnGridMap{mp, make(map[Node]int)}
+	expected, err := FindPath(uncached, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repeated := false
+	for _, n := range uncached.calls {
+		if n > 1 {
+			repeated = true
+		}
+	}
+	if !repeated {
+		t.Fatal("Expected some heuristic costs to be computed more than once without caching")
+	}
+
+	cached := cachedTurnGridMap{turnGridMap{mp, make(map[Node]int)}}
+	path, err := FindPath(cached, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, expected) {
+		t.Fatalf("Expected path %v instead of %v", expected, path)
+	}
+	for node, n := range cached.calls {
+		if n > 1 {
+			t.Fatalf("Heuristic cost of node %d computed %d times", node, n)
+		}
+	}
+}
+
+func TestFindPathFringe(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		mp := randomGridMap(rnd, 30, 30, 0.3)
+		start, end := Node(0), Node(len(mp.grid)-1)
+		mp.grid[start] = 0
+		mp.grid[end] = 0
+		_, expected, expectedErr := FindPathCost(mp, start, end)
+		path, err := FindPathFringe(mp, start, end)
+		if !sameError(err, expectedErr) {
+			t.Fatalf("Expected error %v instead of %v", expectedErr, err)
+		}
+		if err != nil {
+			continue
+		}
+		if path[0] != start || path[len(path)-1] != end {
+			t.Fatalf("Expected path from %d to %d instead of %v", start, end, path)
+		}
+		if cost := gridPathCost(t, mp, path); math.Abs(cost-expected) > 1e-3 {
+			t.Fatalf("Expected cost %f instead of %f", expected, cost)
+		}
+	}
+}
+
+func BenchmarkFindPathFringe(b *testing.B) {
+	mp := &gridMap{
+		grid:   make([]int, 100*100),
+		width:  100,
+		height: 100,
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		FindPathFringe(mp, 0, Node(len(mp.grid)-1))
+	}
+}
+
+func TestFindPathIDA(t *testing.T) {
+	mp := testGrid()
+	start, end := Node(5*mp.width), Node(3*mp.width+9)
+	_, expected, err := FindPathCost(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := FindPathIDA(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path[0] != start || path[len(path)-1] != end {
+		t.Fatalf("Expected path from %d to %d instead of %v", start, end, path)
+	}
+	if cost := gridPathCost(t, mp, path); math.Abs(cost-expected) > 1e-3 {
+		t.Fatalf("Expected cost %f instead of %f", expected, cost)
+	}
+
+	g := adjGraph{
+		0: {{1, 1}},
+		1: {{0, 1}, {2, 1}},
+		2: {{1, 1}},
+	}
+	if _, err := FindPathIDA(g, 0, 3); !errors.Is(err, ErrImpossible) {
+		t.Fatalf("Expected ErrImpossible instead of %v", err)
+	}
+}
+
+func TestFindPathBatch(t *testing.T) {
+	mp := randomGridMap(rand.New(rand.NewSource(1)), 30, 30, 0.3)
+	rnd := rand.New(rand.NewSource(2))
+	queries := make([][2]Node, 50)
+	for i := range queries {
+		queries[i] = [2]Node{Node(rnd.Intn(len(mp.grid))), Node(rnd.Intn(len(mp.grid)))}
+	}
+	for _, workers := range []int{0, 1, 4, 100} {
+		paths, errs := FindPathBatch(mp, queries, workers)
+		if len(paths) != len(queries) || len(errs) != len(queries) {
+			t.Fatalf("Expected %d results instead of %d paths and %d errors", len(queries), len(paths), len(errs))
+		}
+		for i, q := range queries {
+			expected, err := FindPath(mp, q[0], q[1])
+			if !sameError(errs[i], err) {
+				t.Fatalf("Expected error %v for query %d instead of %v", err, i, errs[i])
+			}
+			if !equalPaths(paths[i], expected) {
+				t.Fatalf("Expected path %v for query %d instead of %v", expected, i, paths[i])
+			}
+		}
+	}
+	if paths, errs := FindPathBatch(mp, nil, 4); len(paths) != 0 || len(errs) != 0 {
+		t.Fatal("Expected no results for no queries")
+	}
+}
+
+func TestInvalidCost(t *testing.T) {
+	for _, cost := range []float64{math.NaN(), -1} {
+		mp := adjGraph{
+			0: {{1, 1}},
+			1: {{2, cost}, {3, 1}},
+			2: {{3, 1}},
+		}
+		_, err := FindPath(mp, 0, 3)
+		if !errors.Is(err, ErrInvalidCost) {
+			t.Fatalf("Expected ErrInvalidCost for cost %f instead of %v", cost, err)
+		}
+		var ice *InvalidCostError
+		if !errors.As(err, &ice) || ice.From != 1 || ice.To != 2 {
+			t.Fatalf("Expected the edge from 1 to 2 to be reported instead of %v", err)
+		}
+	}
+}
+
+// untouchedGraph fails the test if it's used
+type untouchedGraph struct {
+	t *testing.T
+}
+
+func (g untouchedGraph) Neighbors(node Node, edges []Edge) ([]Edge, error) {
+	g.t.Fatalf("Neighbors(%d) called", node)
+	return edges, nil
+}
+
+func (g untouchedGraph) HeuristicCost(start, end Node) (float64, error) {
+	g.t.Fatalf("HeuristicCost(%d, %d) called", start, end)
+	return 0, nil
+}
+
+func TestStartIsEnd(t *testing.T) {
+	mp := untouchedGraph{t}
+	path, cost, err := FindPathCost(mp, 7, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, []Node{7}) || cost != 0 {
+		t.Fatalf("Expected path [7] with cost 0 instead of %v with cost %f", path, cost)
+	}
+	path, err = FindPathInto(mp, 7, 7, make([]Node, 3, 8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, []Node{7}) {
+		t.Fatalf("Expected path [7] instead of %v", path)
+	}
+	var s Searcher
+	if path, err := s.FindPath(mp, 7, 7); err != nil || !equalPaths(path, []Node{7}) {
+		t.Fatalf("Expected path [7] instead of %v, %v", path, err)
+	}
+}
+
+// dedupeGraph is an adjGraph whose duplicate edges are collapsed
+type dedupeGraph struct {
+	adjGraph
+}
+
+func (dedupeGraph) DuplicateEdges() {}
+
+func TestSelfLoopsAndDuplicateEdges(t *testing.T) {
+	// A self-loop would let the search avoid the turn penalty
+	mp := adjGraph{
+		0: {{1, 1}},
+		1: {{1, 0}, {2, 1}},
+	}
+	tg := turnGraph{mp, func(from, via, to Node) float64 {
+		if from == 0 && via == 1 && to == 2 {
+			return 10
+		}
+		return 0
+	}}
+	path, cost, err := FindPathCost(tg, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, []Node{0, 1, 2}) || cost != 12 {
+		t.Fatalf("Expected path [0 1 2] with cost 12 instead of %v with cost %f", path, cost)
+	}
+
+	mp = adjGraph{
+		0: {{1, 5}, {2, 1}, {1, 1}},
+		1: {{3, 1}},
+		2: {{3, 5}},
+	}
+	path, stats, err := FindPathStats(mp, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, []Node{0, 1, 3}) || stats.CostUpdates != 1 {
+		t.Fatalf("Expected path [0 1 3] with 1 cost update instead of %v with %d", path, stats.CostUpdates)
+	}
+	path, stats, err = FindPathStats(dedupeGraph{mp}, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, []Node{0, 1, 3}) || stats.CostUpdates != 0 {
+		t.Fatalf("Expected path [0 1 3] with no cost updates instead of %v with %d", path, stats.CostUpdates)
+	}
+	if mp[0][0].Cost != 5 {
+		t.Fatal("Duplicate edges were modified in the graph")
+	}
+}
+
+// sliceOpenList is an unsorted open list which scans for the best node
+type sliceOpenList struct {
+	nodes   []*OpenNode
+	updates int
+}
+
+func (l *sliceOpenList) Push(ni *OpenNode) {
+	l.nodes = append(l.nodes, ni)
+}
+
+func (l *sliceOpenList) PopBest() *OpenNode {
+	best := 0
+	for i, ni := range l.nodes {
+		if ni.Less(l.nodes[best]) {
+			best = i
+		}
+	}
+	ni := l.nodes[best]
+	l.nodes[best] = l.nodes[len(l.nodes)-1]
+	l.nodes = l.nodes[:len(l.nodes)-1]
+	return ni
+}
+
+func (l *sliceOpenList) Update(ni *OpenNode) {
+	l.updates++
+}
+
+func (l *sliceOpenList) Len() int {
+	return len(l.nodes)
+}
+
+func TestFindPathWith(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	updates := 0
+	for i := 0; i < 10; i++ {
+		mp := randomGridMap(rnd, 30, 30, 0.3)
+		start, end := Node(0), Node(len(mp.grid)-1)
+		mp.grid[start] = 0
+		mp.grid[end] = 0
+		expected, expectedErr := FindPath(mp, start, end)
+		ol := &sliceOpenList{}
+		path, err := FindPathWith(mp, start, end, ol)
+		if !sameError(err, expectedErr) {
+			t.Fatalf("Expected error %v instead of %v", expectedErr, err)
+		}
+		if !equalPaths(path, expected) {
+			t.Fatalf("Expected path %v instead of %v", expected, path)
+		}
+		updates += ol.updates
+	}
+	if updates == 0 {
+		t.Fatal("Expected Update to be called")
+	}
+}
+
+func TestInfiniteHeuristic(t *testing.T) {
+	adj := adjGraph{
+		0: {{1, 5}, {2, 1}},
+		1: {{3, 5}},
+		2: {{3, 1}, {4, 1}},
+		4: {{3, 1}},
+	}
+	mp := NewGraphFunc(adj.Neighbors, func(start, end Node) (float64, error) {
+		// The heuristic claims the goal can't be reached through node 2
+		if start == 2 {
+			return math.Inf(1), nil
+		}
+		return 0, nil
+	})
+	path, stats, err := FindPathStats(mp, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, []Node{0, 1, 3}) {
+		t.Fatalf("Expected path [0 1 3] instead of %v", path)
+	}
+	if stats.NodesGenerated != 3 {
+		t.Fatalf("Expected 3 nodes generated instead of %d", stats.NodesGenerated)
+	}
+	_, explored, err := FindPathExplored(mp, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []Node{2, 4} {
+		if _, ok := explored[n]; ok {
+			t.Fatalf("Node %d was expanded", n)
+		}
+	}
+	if _, err := FindPath(mp, 2, 3); !errors.Is(err, ErrImpossible) {
+		t.Fatalf("Expected ErrImpossible from a start with an infinite heuristic instead of %v", err)
+	}
+}
+
+// congestedGraph is an adjGraph where edges into node 3 are congested
+// until time 2
+type congestedGraph struct {
+	adjGraph
+}
+
+func (g congestedGraph) EdgeCostAt(from, to Node, arrivalCost float64) float64 {
+	cost := 0.0
+	for _, e := range g.adjGraph[from] {
+		if e.Node == to {
+			cost = e.Cost
+		}
+	}
+	if to == 3 && arrivalCost < 2 {
+		cost += 9
+	}
+	return cost
+}
+
+func TestTimeDependentGraph(t *testing.T) {
+	mp := adjGraph{
+		0: {{1, 2}, {2, 1}},
+		1: {{3, 1}},
+		2: {{3, 1}},
+	}
+	path, cost, err := FindPathCost(mp, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, []Node{0, 2, 3}) || cost != 2 {
+		t.Fatalf("Expected path [0 2 3] with cost 2 instead of %v with cost %f", path, cost)
+	}
+	// Arriving at node 1 later avoids the congestion
+	path, cost, err = FindPathCost(congestedGraph{mp}, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, []Node{0, 1, 3}) || cost != 3 {
+		t.Fatalf("Expected path [0 1 3] with cost 3 instead of %v with cost %f", path, cost)
+	}
+}
+
+func TestFindPathTree(t *testing.T) {
+	mp := testGrid()
+	start, end := Node(5*mp.width), Node(3*mp.width+9)
+	path, tree, err := FindPathTree(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, stats, _ := FindPathStats(mp, start, end)
+	if len(tree) != stats.NodesGenerated-1 {
+		t.Fatalf("Expected %d nodes in the tree instead of %d", stats.NodesGenerated-1, len(tree))
+	}
+	if _, ok := tree[start]; ok {
+		t.Fatal("Expected the start to have no parent")
+	}
+	// Following the parents from the end gives the path
+	n := end
+	for i := len(path) - 1; i > 0; i-- {
+		if path[i] != n {
+			t.Fatalf("Expected node %d at index %d of %v", n, i, path)
+		}
+		n = tree[n]
+	}
+	if n != start {
+		t.Fatalf("Expected the tree to lead back to %d instead of %d", start, n)
+	}
+	for child, parent := range tree {
+		if _, ok := tree[parent]; !ok && parent != start {
+			t.Fatalf("Parent %d of %d isn't in the tree", parent, child)
+		}
+	}
+}
+
+func TestCostPrecision(t *testing.T) {
+	// The costs of the two paths differ by less than float32 can resolve
+	mp := adjGraph{
+		0: {{1, 1e8}, {2, 1e8}},
+		1: {{3, 2}},
+		2: {{3, 1}},
+	}
+	path, cost, err := FindPathCost(mp, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, []Node{0, 2, 3}) || cost != 1e8+1 {
+		t.Fatalf("Expected path [0 2 3] with cost %f instead of %v with cost %f", 1e8+1, path, cost)
+	}
+}
+
+func TestDefaultCapacity(t *testing.T) {
+	cases := []struct {
+		a, b     Node
+		expected int
+	}{
+		{0, 10, 10},
+		{10, 0, 10},
+		{0, math.MaxInt64 - 1, maxDefaultMapCapacity},
+		{math.MinInt64, math.MaxInt64, maxDefaultMapCapacity},
+		{math.MaxInt64, math.MinInt64, maxDefaultMapCapacity},
+		{-5, 5, 10},
+	}
+	for _, c := range cases {
+		if n := defaultCapacity(c.a, c.b); n != c.expected {
+			t.Errorf("Expected capacity %d for %d to %d instead of %d", c.expected, c.a, c.b, n)
+		}
+	}
+
+	mp := adjGraph{0: {{1, 1}}}
+	for _, end := range []Node{math.MaxInt64 - 1, math.MinInt64} {
+		if _, err := FindPath(mp, 0, end); !errors.Is(err, ErrImpossible) {
+			t.Fatalf("Expected ErrImpossible for end %d instead of %v", end, err)
+		}
+	}
+	if _, err := FindPath(mp, math.MaxInt64, math.MinInt64+1); !errors.Is(err, ErrImpossible) {
+		t.Fatalf("Expected ErrImpossible instead of %v", err)
+	}
+}
+
+// eventGridMap records the nodes generated and visited by a search
+type eventGridMap struct {
+	*gridMap
+	events *[]string
+}
+
+func (g eventGridMap) VisitedNode(node, parentNode Node, currentCost, predictedCost float64) {
+	*g.events = append(*g.events, fmt.Sprintf("v%d", node))
+}
+
+func (g eventGridMap) GeneratedNode(node, parentNode Node, currentCost, predictedCost float64) {
+	*g.events = append(*g.events, fmt.Sprintf("g%d<%d", node, parentNode))
+}
+
+func TestGenerationDebug(t *testing.T) {
+	mp := randomGridMap(rand.New(rand.NewSource(1)), 20, 20, 0.2)
+	start, end := Node(0), Node(len(mp.grid)-1)
+	mp.grid[start] = 0
+	mp.grid[end] = 0
+	var events []string
+	_, stats, err := FindPathStats(eventGridMap{mp, &events}, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) == 0 || events[0] != "g0<-1" {
+		t.Fatalf("Expected the start to be generated first instead of %v", events)
+	}
+	generated := make(map[Node]bool)
+	visited := 0
+	for _, e := range events {
+		var node, parent Node
+		if _, err := fmt.Sscanf(e, "g%d<%d", &node, &parent); err == nil {
+			if generated[node] {
+				t.Fatalf("Node %d generated twice", node)
+			}
+			if node != start && !generated[parent] {
+				t.Fatalf("Node %d generated before its parent %d", node, parent)
+			}
+			generated[node] = true
+		} else if _, err := fmt.Sscanf(e, "v%d", &node); err == nil {
+			if !generated[node] {
+				t.Fatalf("Node %d visited before it was generated", node)
+			}
+			visited++
+		}
+	}
+	if len(generated) != stats.NodesGenerated || visited != stats.NodesExpanded {
+		t.Fatalf("Expected %d generated and %d visited instead of %d and %d",
+			stats.NodesGenerated, stats.NodesExpanded, len(generated), visited)
+	}
+}
+
+func TestLandmarks(t *testing.T) {
+	mp := revGridMap{randomGridMap(rand.New(rand.NewSource(3)), 30, 30, 0.2)}
+	corners := []Node{0, 29, 30 * 29, 30*30 - 1}
+	for _, n := range corners {
+		mp.grid[n] = 0
+	}
+	var lm Landmarks
+	if err := lm.Precompute(mp, corners); err != nil {
+		t.Fatal(err)
+	}
+	alt := NewGraphFunc(mp.Neighbors, func(a, b Node) (float64, error) {
+		return lm.Heuristic(a, b), nil
+	})
+	rnd := rand.New(rand.NewSource(4))
+	altExpanded, dijkstraExpanded := 0, 0
+	for i := 0; i < 50; i++ {
+		start, end := Node(rnd.Intn(len(mp.grid))), Node(rnd.Intn(len(mp.grid)))
+		if mp.grid[start] != 0 || mp.grid[end] != 0 {
+			continue
+		}
+		h := lm.Heuristic(start, end)
+		_, expected, err := FindPathCost(mp, start, end)
+		if errors.Is(err, ErrImpossible) {
+			continue
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if h > expected+1e-9 {
+			t.Fatalf("Heuristic %f from %d to %d exceeds the cost %f", h, start, end, expected)
+		}
+		_, cost, err := findPath(alt, start, end, config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(cost-expected) > 1e-9 {
+			t.Fatalf("Expected cost %f from %d to %d instead of %f", expected, start, end, cost)
+		}
+		_, stats, _ := FindPathStats(alt, start, end)
+		altExpanded += stats.NodesExpanded
+		_, stats, _ = FindPathStats(zeroHeuristic{mp}, start, end)
+		dijkstraExpanded += stats.NodesExpanded
+	}
+	if altExpanded >= dijkstraExpanded {
+		t.Fatalf("Expected landmarks to expand fewer nodes than Dijkstra (%d >= %d)", altExpanded, dijkstraExpanded)
+	}
+
+	// A node surrounded by walls can't be reached
+	isolated := Node(5*mp.width + 5)
+	mp.grid[isolated] = 0
+	edges, _ := mp.gridMap.Neighbors(isolated, nil)
+	for _, e := range edges {
+		mp.grid[e.Node] = 1
+	}
+	if err := lm.Precompute(mp, corners); err != nil {
+		t.Fatal(err)
+	}
+	if h := lm.Heuristic(0, isolated); !math.IsInf(h, 1) {
+		t.Fatalf("Expected an infinite heuristic to an unreachable node instead of %f", h)
+	}
+}
+
+func TestFindPathBeam(t *testing.T) {
+	mp := testGrid()
+	start, end := Node(5*mp.width+3), Node(3*mp.width+9)
+	// A narrow beam heads straight for the wall and loses the way around
+	if _, err := FindPathBeam(mp, start, end, 1); !errors.Is(err, ErrImpossible) {
+		t.Fatalf("Expected ErrImpossible for a narrow beam instead of %v", err)
+	}
+	path, err := FindPathBeam(mp, start, end, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path[0] != start || path[len(path)-1] != end {
+		t.Fatalf("Expected path from %d to %d instead of %v", start, end, path)
+	}
+	gridPathCost(t, mp, path)
+}
+
+// noUTurnGraph is an adjGraph on which a path can't turn straight back
+// or make any of the banned turns.
+type noUTurnGraph struct {
+	adjGraph
+	banned map[[3]Node]bool // from, via, to
+}
+
+func (g noUTurnGraph) NeighborsFrom(node, parent Node, edges []Edge) ([]Edge, error) {
+	for _, e := range g.adjGraph[node] {
+		if e.Node != parent && !g.banned[[3]Node{parent, node, e.Node}] {
+			edges = append(edges, e)
+		}
+	}
+	return edges, nil
+}
+
+func TestDirectionalGraph(t *testing.T) {
+	// A road from 0 through 1 to 3 with a loop 2-4-5 to turn around in
+	mp := adjGraph{
+		0: {{1, 1}},
+		1: {{0, 1}, {2, 1}, {3, 1}},
+		2: {{1, 1}, {4, 1}, {5, 1}},
+		3: {{1, 1}},
+		4: {{2, 1}, {5, 1}},
+		5: {{2, 1}, {4, 1}},
+	}
+	path, err := FindPath(mp, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, []Node{0, 1, 3}) {
+		t.Fatalf("Expected path [0 1 3] instead of %v", path)
+	}
+
+	// Turning from 0 onto 3 is banned so the path has to go around the
+	// loop and come back through 1 and 2.
+	dg := noUTurnGraph{mp, map[[3]Node]bool{{0, 1, 3}: true}}
+	path, cost, err := FindPathCost(dg, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Node{0, 1, 2, 4, 5, 2, 1, 3}
+	if !equalPaths(path, expected) && !equalPaths(path, []Node{0, 1, 2, 5, 4, 2, 1, 3}) {
+		t.Fatalf("Expected path %v instead of %v", expected, path)
+	}
+	if cost != 7 {
+		t.Fatalf("Expected cost 7 instead of %f", cost)
+	}
+
+	// Without the loop there's no way to turn around
+	delete(dg.adjGraph, 4)
+	if _, err := FindPath(dg, 0, 3); !errors.Is(err, ErrImpossible) {
+		t.Fatalf("Expected ErrImpossible instead of %v", err)
+	}
+}
+
+// buggyGridMap returns an extra edge to a node past the end of the grid,
+// which the gridMap would panic expanding, but reports it invalid.
+type buggyGridMap struct {
+	*gridMap
+}
+
+func (g buggyGridMap) Neighbors(node Node, edges []Edge) ([]Edge, error) {
+	edges, err := g.gridMap.Neighbors(node, edges)
+	return append(edges, Edge{Node(len(g.grid)), 0}), err
+}
+
+func (g buggyGridMap) Valid(node Node) bool {
+	return node >= 0 && int(node) < len(g.grid)
+}
+
+func TestValidator(t *testing.T) {
+	mp := randomGridMap(rand.New(rand.NewSource(4)), 20, 20, 0.2)
+	mp.grid[0], mp.grid[len(mp.grid)-1] = 0, 0
+	expected, err := FindPath(mp, 0, Node(len(mp.grid)-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, stats, err := FindPathStats(buggyGridMap{mp}, 0, Node(len(mp.grid)-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, expected) {
+		t.Fatalf("Expected path %v instead of %v", expected, path)
+	}
+	if stats.InvalidEdges != stats.NodesExpanded {
+		t.Fatalf("Expected %d invalid edges instead of %d", stats.NodesExpanded, stats.InvalidEdges)
+	}
+}
+
+func TestFindPathDetailed(t *testing.T) {
+	mp := randomGridMap(rand.New(rand.NewSource(5)), 20, 20, 0.2)
+	start, end := Node(0), Node(len(mp.grid)-1)
+	mp.grid[start], mp.grid[end] = 0, 0
+	expected, cost, err := FindPathCost(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := FindPathDetailed(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) != len(expected) {
+		t.Fatalf("Expected %d nodes instead of %d", len(expected), len(path))
+	}
+	for i, pn := range path {
+		if pn.Node != expected[i] {
+			t.Fatalf("Expected node %d at %d instead of %d", expected[i], i, pn.Node)
+		}
+		if c := gridPathCost(t, mp, expected[:i+1]); math.Abs(pn.Cost-c) > 1e-9 {
+			t.Fatalf("Expected cost %f at node %d instead of %f", c, i, pn.Cost)
+		}
+	}
+	if last := path[len(path)-1].Cost; last != cost {
+		t.Fatalf("Expected total cost %f instead of %f", cost, last)
+	}
+
+	path, err = FindPathDetailed(mp, start, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) != 1 || path[0] != (PathNode{start, 0}) {
+		t.Fatalf("Expected just the start instead of %v", path)
+	}
+}
+
+func TestFindPathAnytime(t *testing.T) {
+	mp := &recordingGridMap{gridMap: randomGridMap(rand.New(rand.NewSource(1)), 40, 40, 0.3)}
+	start, end := Node(0), Node(len(mp.grid)-1)
+	mp.grid[start] = 0
+	mp.grid[end] = 0
+	_, expected, err := FindPathCost(mp.gridMap, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := FindPathAnytime(mp, start, end, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path[0] != start || path[len(path)-1] != end {
+		t.Fatalf("Expected path from %d to %d instead of %v", start, end, path)
+	}
+	if cost := gridPathCost(t, mp.gridMap, path); math.Abs(cost-expected) > 1e-9 {
+		t.Fatalf("Expected optimal cost %f instead of %f", expected, cost)
+	}
+	if len(mp.possible) < 2 {
+		t.Fatalf("Expected the path to improve over time instead of %d possible paths", len(mp.possible))
+	}
+	for i, p := range mp.possible {
+		if i > 0 && p.cost >= mp.possible[i-1].cost {
+			t.Fatalf("Expected possible path %d to cost less than %f instead of %f", i, mp.possible[i-1].cost, p.cost)
+		}
+		if c := gridPathCost(t, mp.gridMap, p.path); math.Abs(c-p.cost) > 1e-9 {
+			t.Fatalf("Possible path %d reported a cost of %f but costs %f", i, p.cost, c)
+		}
+		if p.cost > 3*expected {
+			t.Fatalf("Possible path %d costs more than 3 times the optimal cost", i)
+		}
+	}
+
+	g := adjGraph{
+		0: {{1, 1}},
+		1: {{0, 1}},
+	}
+	if _, err := FindPathAnytime(g, 0, 2, 2); !errors.Is(err, ErrImpossible) {
+		t.Fatalf("Expected ErrImpossible instead of %v", err)
+	}
+}
+
+func TestReachable(t *testing.T) {
+	mp := randomGridMap(rand.New(rand.NewSource(7)), 20, 20, 0.3)
+	start := Node(0)
+	mp.grid[start] = 0
+	all, err := Reachable(mp, start, math.Inf(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	within, err := Reachable(mp, start, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := range mp.grid {
+		node := Node(n)
+		_, cost, err := FindPathCost(mp, start, node)
+		if errors.Is(err, ErrImpossible) {
+			if _, ok := all[node]; ok {
+				t.Fatalf("Node %d can't be reached but was reported reachable", node)
+			}
+			continue
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if c, ok := all[node]; !ok || math.Abs(c-cost) > 1e-9 {
+			t.Fatalf("Expected node %d to be reachable for %f instead of %f (%t)", node, cost, c, ok)
+		}
+		if _, ok := within[node]; ok != (cost <= 8) {
+			t.Fatalf("Node %d costs %f but within 8 is %t", node, cost, ok)
+		}
+		reachable, err := IsReachable(mp, start, node)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reachable {
+			t.Fatalf("Expected node %d to be reachable", node)
+		}
+	}
+	if len(within) >= len(all) {
+		t.Fatalf("Expected fewer nodes within 8 than the %d reachable", len(all))
+	}
+
+	g := adjGraph{
+		0: {{1, 2}},
+		1: {{2, 3}},
+	}
+	if r, err := Reachable(g, 0, 5); err != nil || len(r) != 3 {
+		t.Fatalf("Expected 3 nodes within a cost of 5 instead of %v (%v)", r, err)
+	}
+	if r, err := Reachable(g, 0, 4.5); err != nil || len(r) != 2 {
+		t.Fatalf("Expected 2 nodes within a cost of 4.5 instead of %v (%v)", r, err)
+	}
+	if r, err := Reachable(g, 0, 0); err != nil || len(r) != 1 {
+		t.Fatalf("Expected only the start within a cost of 0 instead of %v (%v)", r, err)
+	}
+	if reachable, err := IsReachable(g, 2, 0); err != nil || reachable {
+		t.Fatalf("Expected 0 to be unreachable from 2 instead of %t (%v)", reachable, err)
+	}
+}
+
+func TestParentCycle(t *testing.T) {
+	s := newState(0)
+	a, b := s.newNodeInfo(), s.newNodeInfo()
+	*a = OpenNode{node: 1, parent: b}
+	*b = OpenNode{node: 2, parent: a}
+	if _, err := s.pathToNode(a); err != errParentCycle {
+		t.Fatalf("Expected errParentCycle instead of %v", err)
+	}
+	if _, err := s.detailedPath(a); err != errParentCycle {
+		t.Fatalf("Expected errParentCycle instead of %v", err)
+	}
+	if _, err := s.reverseParents(a); err != errParentCycle || a.parent != b || b.parent != a {
+		t.Fatalf("Expected errParentCycle with the links unchanged instead of %v", err)
+	}
+	b.parent = nil
+	path, err := s.pathToNode(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, []Node{2, 1}) {
+		t.Fatalf("Expected path [2 1] instead of %v", path)
+	}
+}
+
+type debugFunc func(node, parentNode Node, currentCost, predictedCost float64)
+
+func (f debugFunc) VisitedNode(node, parentNode Node, currentCost, predictedCost float64) {
+	f(node, parentNode, currentCost, predictedCost)
+}
+
+func TestFindPathOpts(t *testing.T) {
+	mp := randomGridMap(rand.New(rand.NewSource(8)), 30, 30, 0.2)
+	start, end := Node(0), Node(len(mp.grid)-1)
+	mp.grid[start] = 0
+	mp.grid[end] = 0
+	expected, cost, err := FindPathCost(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := FindPathOpts(mp, start, end, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, expected) {
+		t.Fatalf("Expected zero options to find %v instead of %v", expected, path)
+	}
+
+	weighted, err := FindPathWeighted(mp, start, end, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	visited := 0
+	opts := Options{
+		HeuristicWeight: 3,
+		MaxCost:         2 * cost,
+		MaxNodes:        len(mp.grid),
+		Debug: debugFunc(func(node, parentNode Node, currentCost, predictedCost float64) {
+			visited++
+		}),
+	}
+	path, err = FindPathOpts(mp, start, end, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, weighted) {
+		t.Fatalf("Expected the weighted path %v instead of %v", weighted, path)
+	}
+	if visited == 0 {
+		t.Fatal("Expected Debug to be called")
+	}
+
+	if _, err := FindPathOpts(mp, start, end, Options{MaxNodes: 5}); err != ErrBudgetExceeded {
+		t.Fatalf("Expected ErrBudgetExceeded instead of %v", err)
+	}
+	if _, err := FindPathOpts(mp, start, end, Options{MaxCost: cost}); !errors.Is(err, ErrImpossible) {
+		t.Fatalf("Expected ErrImpossible instead of %v", err)
+	}
+}
+
+// undirectedGridMap is a gridMap declared Undirected, which it is since
+// edges between walkable cells go both ways at the same cost.
+type undirectedGridMap struct {
+	*gridMap
+}
+
+func (undirectedGridMap) Undirected() {}
+
+func TestUndirected(t *testing.T) {
+	mp := randomGridMap(rand.New(rand.NewSource(2)), 20, 20, 0.25)
+	goal := Node(10*mp.width + 10)
+	mp.grid[goal] = 0
+	expected, err := CostField(revGridMap{mp}, goal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	field, err := CostField(undirectedGridMap{mp}, goal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(field) != len(expected) {
+		t.Fatalf("Expected %d nodes in the cost field instead of %d", len(expected), len(field))
+	}
+	for node, c := range expected {
+		if math.Abs(field[node]-c) > 1e-9 {
+			t.Fatalf("Expected cost %f for node %d instead of %f", c, node, field[node])
+		}
+	}
+	if _, err := CostField(mp, goal); err != ErrNotReversible {
+		t.Fatalf("Expected ErrNotReversible instead of %v", err)
+	}
+
+	var lm Landmarks
+	if err := lm.Precompute(undirectedGridMap{mp}, []Node{0, 399}); err != nil {
+		t.Fatal(err)
+	}
+	if err := lm.Precompute(mp, []Node{0, 399}); err != ErrNotReversible {
+		t.Fatalf("Expected ErrNotReversible instead of %v", err)
+	}
+}
+
+func TestFindPathResumable(t *testing.T) {
+	mp := randomGridMap(rand.New(rand.NewSource(9)), 30, 30, 0.2)
+	start, end := Node(0), Node(len(mp.grid)-1)
+	mp.grid[start] = 0
+	mp.grid[end] = 0
+	expected, stats, err := FindPathStats(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, st, err := FindPathResumable(mp, start, end, 10)
+	if err != ErrBudgetExceeded {
+		t.Fatalf("Expected ErrBudgetExceeded instead of %v", err)
+	}
+	frontier, ferr := st.Frontier()
+	if ferr != nil {
+		t.Fatal(ferr)
+	}
+	if len(frontier) == 0 {
+		t.Fatal("Expected nodes on the frontier")
+	}
+	for _, p := range frontier {
+		if p[0] != start {
+			t.Fatalf("Expected frontier path to start at %d instead of %v", start, p)
+		}
+	}
+	resumes := 0
+	for err == ErrBudgetExceeded {
+		if st.Done() {
+			t.Fatal("Search is done but returned ErrBudgetExceeded")
+		}
+		path, err = Resume(st, 10)
+		resumes++
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !st.Done() {
+		t.Fatal("Expected the search to be done")
+	}
+	if !equalPaths(path, expected) {
+		t.Fatalf("Expected path %v instead of %v", expected, path)
+	}
+	if s := st.Stats(); s.NodesExpanded != stats.NodesExpanded {
+		t.Fatalf("Expected %d nodes expanded across %d resumes instead of %d", stats.NodesExpanded, resumes, s.NodesExpanded)
+	}
+	if again, err := Resume(st, 10); err != nil || !equalPaths(again, expected) {
+		t.Fatalf("Expected resuming a finished search to return its path instead of %v (%v)", again, err)
+	}
+
+	// A cancelled search can be resumed too
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, st, err = FindPathResumableContext(ctx, mp, start, end, 1<<20)
+	if err != context.Canceled || st.Done() {
+		t.Fatalf("Expected an unfinished search with context.Canceled instead of %v", err)
+	}
+	if _, err := Resume(st, 10); err != ErrBudgetExceeded {
+		t.Fatalf("Expected ErrBudgetExceeded instead of %v", err)
+	}
+	if _, err := ResumeContext(ctx, st, 1<<20); err != context.Canceled || st.Done() {
+		t.Fatalf("Expected an unfinished search with context.Canceled instead of %v", err)
+	}
+	path, err = Resume(st, 1<<20)
+	if err != nil || !equalPaths(path, expected) {
+		t.Fatalf("Expected path %v instead of %v (%v)", expected, path, err)
+	}
+	if s := st.Stats(); s.NodesExpanded != stats.NodesExpanded {
+		t.Fatalf("Expected %d nodes expanded after cancelling instead of %d", stats.NodesExpanded, s.NodesExpanded)
+	}
+
+	mp.grid[end] = 1
+	_, st, err = FindPathResumable(mp, start, end, 1<<20)
+	if !errors.Is(err, ErrImpossible) || !st.Done() {
+		t.Fatalf("Expected a finished search with ErrImpossible instead of %v", err)
+	}
+}
+
+func TestFindPathFunc(t *testing.T) {
+	mp := randomGridMap(rand.New(rand.NewSource(10)), 10, 10, 0.2)
+	start := Node(5 * mp.width)
+	mp.grid[start] = 0
+	inColumn9 := func(n Node) bool { return int(n)%mp.width == 9 }
+	var column []Node
+	for y := 0; y < mp.height; y++ {
+		column = append(column, Node(y*mp.width+9))
+	}
+	expected, _, err := FindPathAny(mp, start, column)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedCost := gridPathCost(t, mp, expected)
+	heuristics := map[string]func(Node) float64{
+		"nil": nil,
+		// Every move changes x by at most 1 at a cost of at least 1
+		"column": func(n Node) float64 { return float64(9 - int(n)%mp.width) },
+	}
+	for name, h := range heuristics {
+		path, err := FindPathFunc(mp, start, inColumn9, h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path[0] != start || !inColumn9(path[len(path)-1]) {
+			t.Fatalf("Expected a path from %d to column 9 with the %s heuristic instead of %v", start, name, path)
+		}
+		if cost := gridPathCost(t, mp, path); math.Abs(cost-expectedCost) > 1e-9 {
+			t.Fatalf("Expected cost %f with the %s heuristic instead of %f", expectedCost, name, cost)
+		}
+	}
+
+	if _, err := FindPathFunc(mp, start, func(Node) bool { return false }, nil); !errors.Is(err, ErrImpossible) {
+		t.Fatalf("Expected ErrImpossible instead of %v", err)
+	}
+}
+
+// checkHeap fails the test if the open list of the state isn't a valid
+// heap or the indexes of its nodes are wrong.
+func checkHeap(t *testing.T, s *state) {
+	for i, ni := range s.heap {
+		if ni.index != i {
+			t.Fatalf("Node %d at %d has index %d", ni.node, i, ni.index)
+		}
+		if p := (i - 1) / 2; i > 0 && s.less(i, p) {
+			t.Fatalf("Node %d at %d is less than its parent %d", ni.node, i, s.heap[p].node)
+		}
+	}
+}
+
+func TestUpdatePoppedNode(t *testing.T) {
+	rnd := rand.New(rand.NewSource(11))
+	s := newState(0)
+	for i := 0; i < 50; i++ {
+		ni := s.newNodeInfo()
+		*ni = OpenNode{node: Node(i), cost: rnd.Float64() * 10, predictedCost: rnd.Float64() * 10}
+		s.addNodeInfo(ni)
+	}
+	checkHeap(t, s)
+	popped := s.popBest()
+	if popped.index != -1 {
+		t.Fatalf("Expected a popped node to have index -1 instead of %d", popped.index)
+	}
+	popped.cost = -1
+	s.updateNodeInfo(popped)
+	checkHeap(t, s)
+	if len(s.heap) != 50 {
+		t.Fatalf("Expected the popped node to be added back instead of %d nodes", len(s.heap))
+	}
+	if best := s.popBest(); best != popped {
+		t.Fatalf("Expected node %d to be the best instead of %d", popped.node, best.node)
+	}
+	checkHeap(t, s)
+}
+
+func TestVerifyPath(t *testing.T) {
+	mp := randomGridMap(rand.New(rand.NewSource(12)), 20, 20, 0.2)
+	start, end := Node(0), Node(len(mp.grid)-1)
+	mp.grid[start] = 0
+	mp.grid[end] = 0
+	path, expected, err := FindPathCost(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cost, err := VerifyPath(mp, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(cost-expected) > 1e-9 {
+		t.Fatalf("Expected cost %f instead of %f", expected, cost)
+	}
+
+	// Dropping a node from the middle leaves a gap
+	i := len(path) / 2
+	broken := append(append([]Node(nil), path[:i]...), path[i+1:]...)
+	_, err = VerifyPath(mp, broken)
+	var mee *MissingEdgeError
+	if !errors.As(err, &mee) || mee.From != path[i-1] || mee.To != path[i+1] || mee.Index != i {
+		t.Fatalf("Expected the missing edge from %d to %d to be reported instead of %v", path[i-1], path[i+1], err)
+	}
+	if !errors.Is(err, ErrInvalidPath) {
+		t.Fatalf("Expected the error to wrap ErrInvalidPath")
+	}
+
+	if _, err := VerifyPath(mp, nil); err != ErrInvalidPath {
+		t.Fatalf("Expected ErrInvalidPath for an empty path instead of %v", err)
+	}
+	if cost, err := VerifyPath(mp, []Node{start}); err != nil || cost != 0 {
+		t.Fatalf("Expected a cost of 0 for a single node instead of %f (%v)", cost, err)
+	}
+	g := adjGraph{0: {{1, 3}, {1, 2}}}
+	if cost, err := VerifyPath(g, []Node{0, 1}); err != nil || cost != 2 {
+		t.Fatalf("Expected the cheapest edge to cost 2 instead of %f (%v)", cost, err)
+	}
+}
+
+// swampGridMap is a gridMap where entering some cells costs extra
+type swampGridMap struct {
+	*gridMap
+	swamp map[Node]float64
+}
+
+func (g swampGridMap) NodeCost(node Node) float64 {
+	return g.swamp[node]
+}
+
+func TestNodeCost(t *testing.T) {
+	mp := &gridMap{grid: make([]int, 5*5), width: 5, height: 5}
+	start, end := Node(2*mp.width), Node(2*mp.width+4)
+	path, cost, err := FindPathCost(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	swamp := Node(2*mp.width + 2)
+	if cost != 4 || path[2] != swamp {
+		t.Fatalf("Expected a straight path through %d instead of %v with cost %f", swamp, path, cost)
+	}
+
+	sg := swampGridMap{mp, map[Node]float64{swamp: 10}}
+	path, cost, err = FindPathCost(sg, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range path {
+		if n == swamp {
+			t.Fatalf("Expected the path to avoid the swamp instead of %v", path)
+		}
+	}
+	if expected := 2 + 2*math.Sqrt2; math.Abs(cost-expected) > 1e-9 {
+		t.Fatalf("Expected cost %f instead of %f", expected, cost)
+	}
+	if c, err := VerifyPath(sg, path); err != nil || math.Abs(c-cost) > 1e-9 {
+		t.Fatalf("Expected VerifyPath to report cost %f instead of %f (%v)", cost, c, err)
+	}
+	if c, err := VerifyPath(sg, []Node{start, start + 1, swamp}); err != nil || c != 12 {
+		t.Fatalf("Expected a cost of 12 through the swamp instead of %f (%v)", c, err)
+	}
+
+	sg.swamp[swamp] = -1
+	if _, err := FindPath(sg, start, end); !errors.Is(err, ErrInvalidCost) {
+		t.Fatalf("Expected ErrInvalidCost for a negative node cost instead of %v", err)
+	}
+}
+
+func TestFindPathGeneral(t *testing.T) {
+	// The rebate on the edge from 2 to 3 makes the long way cheapest
+	g := adjGraph{
+		0: {{1, 1}, {2, 4}},
+		1: {{3, 2}},
+		2: {{3, -3}},
+		3: {{4, 1}},
+	}
+	path, err := FindPathGeneral(g, 0, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, []Node{0, 2, 3, 4}) {
+		t.Fatalf("Expected path [0 2 3 4] instead of %v", path)
+	}
+	if cost := g.pathCost(t, path); cost != 2 {
+		t.Fatalf("Expected cost 2 instead of %f", cost)
+	}
+
+	// Without negative costs it agrees with FindPath
+	mp := randomGridMap(rand.New(rand.NewSource(1)), 50, 50, 0.2)
+	start, end := Node(0), Node(len(mp.grid)-1)
+	mp.grid[start] = 0
+	mp.grid[end] = 0
+	_, expected, err := FindPathCost(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err = FindPathGeneral(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost := gridPathCost(t, mp, path); math.Abs(cost-expected) > 1e-9 {
+		t.Fatalf("Expected cost %f instead of %f", expected, cost)
+	}
+
+	if _, err := FindPathGeneral(g, 4, 0); !errors.Is(err, ErrImpossible) {
+		t.Fatalf("Expected ErrImpossible instead of %v", err)
+	}
+	// A negative cycle not on the way to the end is still reported
+	g[3] = append(g[3], Edge{5, 1})
+	g[5] = []Edge{{6, -2}}
+	g[6] = []Edge{{5, 1}}
+	if _, err := FindPathGeneral(g, 0, 4); err != ErrNegativeCycle {
+		t.Fatalf("Expected ErrNegativeCycle instead of %v", err)
+	}
+	if _, err := FindPathGeneral(adjGraph{0: {{0, -1}}}, 0, 1); err != ErrNegativeCycle {
+		t.Fatalf("Expected ErrNegativeCycle for a negative self-loop instead of %v", err)
+	}
+}
+
+func TestDebugEvery(t *testing.T) {
+	mp := randomGridMap(rand.New(rand.NewSource(8)), 30, 30, 0.2)
+	start, end := Node(0), Node(len(mp.grid)-1)
+	mp.grid[start] = 0
+	mp.grid[end] = 0
+	for _, every := range []int{0, 1, 7} {
+		var visited []Node
+		opts := Options{
+			Debug: debugFunc(func(node, parentNode Node, currentCost, predictedCost float64) {
+				visited = append(visited, node)
+			}),
+			DebugEvery: every,
+		}
+		path, err := FindPathOpts(mp, start, end, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, stats, err := FindPathStats(mp, start, end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := stats.NodesExpanded
+		if every > 1 {
+			expected = (expected + every - 1) / every
+		}
+		if len(visited) != expected {
+			t.Fatalf("Expected %d calls to Debug every %d nodes instead of %d", expected, every, len(visited))
+		}
+		if visited[0] != start {
+			t.Fatalf("Expected the first call to be for the start instead of %d", visited[0])
+		}
+		if len(path) == 0 {
+			t.Fatal("Expected a path")
+		}
+	}
+}
+
+func TestCostMatrix(t *testing.T) {
+	mp := randomGridMap(rand.New(rand.NewSource(1)), 50, 50, 0.2)
+	rnd := rand.New(rand.NewSource(2))
+	nodes := make([]Node, 6)
+	for i := range nodes {
+		nodes[i] = Node(rnd.Intn(len(mp.grid)))
+		mp.grid[nodes[i]] = 0
+	}
+	// An unreachable node and a repeated one
+	walled := Node(10*mp.width + 10)
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			mp.grid[walled+Node(dy*mp.width+dx)] = 1
+		}
+	}
+	mp.grid[walled] = 0
+	nodes = append(nodes, walled, nodes[0])
+
+	matrix, err := CostMatrix(mp, nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matrix) != len(nodes) {
+		t.Fatalf("Expected %d rows instead of %d", len(nodes), len(matrix))
+	}
+	for i, start := range nodes {
+		for j, end := range nodes {
+			expected := math.Inf(1)
+			if _, cost, err := FindPathCost(mp, start, end); err == nil {
+				expected = cost
+			} else if !errors.Is(err, ErrImpossible) {
+				t.Fatal(err)
+			}
+			if c := matrix[i][j]; c != expected && math.Abs(c-expected) > 1e-9 {
+				t.Fatalf("Expected cost %f from %d to %d instead of %f", expected, start, end, c)
+			}
+		}
+	}
+	if !math.IsInf(matrix[0][6], 1) || matrix[6][6] != 0 {
+		t.Fatalf("Expected the walled node to be unreachable but reach itself instead of %f and %f", matrix[0][6], matrix[6][6])
+	}
+}
+
+// noHeuristicGridMap is a gridMap whose HeuristicCost fails the test
+type noHeuristicGridMap struct {
+	*gridMap
+	t *testing.T
+}
+
+func (g noHeuristicGridMap) HeuristicCost(start, end Node) (float64, error) {
+	g.t.Fatalf("HeuristicCost(%d, %d) called", start, end)
+	return 0, nil
+}
+
+func TestIgnoreHeuristic(t *testing.T) {
+	mp := randomGridMap(rand.New(rand.NewSource(1)), 50, 50, 0.2)
+	start, end := Node(0), Node(len(mp.grid)-1)
+	mp.grid[start] = 0
+	mp.grid[end] = 0
+	_, expected, err := FindPathCost(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := FindPathOpts(noHeuristicGridMap{mp, t}, start, end, Options{IgnoreHeuristic: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost := gridPathCost(t, mp, path); math.Abs(cost-expected) > 1e-9 {
+		t.Fatalf("Expected cost %f instead of %f", expected, cost)
+	}
+}
+
+func TestMaxPathLength(t *testing.T) {
+	// The cheapest path takes five edges and the detour through 5 two
+	g := adjGraph{
+		0: {{1, 1}, {5, 4}},
+		1: {{2, 1}},
+		2: {{3, 1}},
+		3: {{4, 1}},
+		4: {{9, 1}},
+		5: {{9, 4}},
+	}
+	cases := []struct {
+		maxLength int
+		path      []Node
+	}{
+		{0, []Node{0, 1, 2, 3, 4, 9}},
+		{5, []Node{0, 1, 2, 3, 4, 9}},
+		{4, []Node{0, 5, 9}},
+		{2, []Node{0, 5, 9}},
+	}
+	for _, c := range cases {
+		path, err := FindPathOpts(g, 0, 9, Options{MaxPathLength: c.maxLength})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equalPaths(path, c.path) {
+			t.Fatalf("Expected %v with a maximum length of %d instead of %v", c.path, c.maxLength, path)
+		}
+	}
+	if _, err := FindPathOpts(g, 0, 9, Options{MaxPathLength: 1}); err != ErrPathTooLong {
+		t.Fatalf("Expected ErrPathTooLong instead of %v", err)
+	}
+	// Unreachable without hitting the limit is still ErrImpossible
+	if _, err := FindPathOpts(g, 9, 0, Options{MaxPathLength: 1}); !errors.Is(err, ErrImpossible) {
+		t.Fatalf("Expected ErrImpossible instead of %v", err)
+	}
+}
+
+// retainingGridMap is a gridMap that keeps the edges it returns for each
+// node, which the Graph contract only allows with Options.CopyEdges.
+type retainingGridMap struct {
+	*gridMap
+	retained map[Node][]Edge
+}
+
+func (g retainingGridMap) Neighbors(node Node, edges []Edge) ([]Edge, error) {
+	edges, err := g.gridMap.Neighbors(node, edges)
+	g.retained[node] = edges
+	return edges, err
+}
+
+func TestCopyEdges(t *testing.T) {
+	mp := randomGridMap(rand.New(rand.NewSource(1)), 30, 30, 0.2)
+	start, end := Node(0), Node(len(mp.grid)-1)
+	mp.grid[start] = 0
+	mp.grid[end] = 0
+	// corrupted returns how many of the retained edge lists are no longer
+	// the edges of their node.
+	corrupted := func(opts Options) int {
+		g := retainingGridMap{mp, make(map[Node][]Edge)}
+		if _, err := FindPathOpts(g, start, end, opts); err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for node, edges := range g.retained {
+			expected, err := mp.Neighbors(node, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(edges, expected) {
+				n++
+			}
+		}
+		return n
+	}
+	// Without copying every retained list shares the search's slice
+	if corrupted(Options{}) == 0 {
+		t.Fatal("Expected retained edges to be overwritten by later calls")
+	}
+	if n := corrupted(Options{CopyEdges: true}); n != 0 {
+		t.Fatalf("Expected every retained edge list to be intact instead of %d overwritten", n)
+	}
+}
+
+// consistentGridMap is a gridMap declared to have a consistent heuristic,
+// which Euclidean distance is.
+type consistentGridMap struct {
+	*gridMap
+}
+
+func (consistentGridMap) ConsistentHeuristic() {}
+
+type consistentAdjGraph struct {
+	adjGraph
+}
+
+func (consistentAdjGraph) ConsistentHeuristic() {}
+
+func TestConsistentHeuristic(t *testing.T) {
+	rnd := rand.New(rand.NewSource(4))
+	for i := 0; i < 20; i++ {
+		mp := randomGridMap(rnd, 30, 20, 0.3)
+		start, end := Node(rnd.Intn(len(mp.grid))), Node(rnd.Intn(len(mp.grid)))
+		mp.grid[start] = 0
+		mp.grid[end] = 0
+		var stats, cstats Stats
+		expected, cost, err := findPath(mp, start, end, config{stats: &stats})
+		path, ccost, cerr := findPath(consistentGridMap{mp}, start, end, config{stats: &cstats})
+		if !sameError(err, cerr) {
+			t.Fatalf("Expected error %v instead of %v", err, cerr)
+		}
+		if !equalPaths(path, expected) || ccost != cost {
+			t.Fatalf("Expected %v with cost %f instead of %v with cost %f", expected, cost, path, ccost)
+		}
+		if cstats.NodesExpanded != stats.NodesExpanded {
+			t.Fatalf("Expected %d nodes expanded instead of %d", stats.NodesExpanded, cstats.NodesExpanded)
+		}
+	}
+
+	// A beam of one drops 2, which is only reached again more cheaply
+	// after 3 and is the only way to the end
+	g := consistentAdjGraph{adjGraph{
+		0: {{1, 1}, {2, 5}},
+		1: {{3, 1}},
+		3: {{2, 1}},
+		2: {{4, 1}},
+	}}
+	if path, err := FindPathBeam(g, 0, 4, 1); err != nil || !equalPaths(path, []Node{0, 1, 3, 2, 4}) {
+		t.Fatalf("Expected [0 1 3 2 4] instead of %v (%v)", path, err)
+	}
+}
+
+func BenchmarkConsistentHeuristic(b *testing.B) {
+	mp := randomGridMap(rand.New(rand.NewSource(1)), 200, 200, 0.1)
+	mp.grid[0] = 0
+	mp.grid[len(mp.grid)-1] = 0
+	for _, g := range []Graph{mp, consistentGridMap{mp}} {
+		name := "General"
+		if _, ok := g.(ConsistentHeuristic); ok {
+			name = "Consistent"
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := FindPath(g, 0, Node(len(mp.grid)-1)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestRandomTieBreak(t *testing.T) {
+	mp := &gridMap{grid: make([]int, 20*20), width: 20, height: 20}
+	start, end := Node(0), Node(19*20+12)
+	expected, cost, err := FindPathCost(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := make(map[string]bool)
+	for seed := int64(0); seed < 20; seed++ {
+		path, err := FindPathOpts(mp, start, end, Options{Rand: rand.New(rand.NewSource(seed))})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c := gridPathCost(t, mp, path); math.Abs(c-cost) > 1e-9 {
+			t.Fatalf("Expected an optimal path costing %f instead of %f", cost, c)
+		}
+		again, err := FindPathOpts(mp, start, end, Options{Rand: rand.New(rand.NewSource(seed))})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equalPaths(path, again) {
+			t.Fatalf("Expected the same path for seed %d instead of %v and %v", seed, path, again)
+		}
+		paths[fmt.Sprint(path)] = true
+	}
+	if len(paths) < 5 {
+		t.Fatalf("Expected at least 5 different paths instead of %d", len(paths))
+	}
+	if path, _ := FindPathOpts(mp, start, end, Options{}); !equalPaths(path, expected) {
+		t.Fatalf("Expected %v without Rand instead of %v", expected, path)
+	}
+
+	// Each of three paths of equal cost is as likely
+	g := adjGraph{
+		0: {{1, 1}, {2, 1}, {3, 1}},
+		1: {{4, 1}},
+		2: {{4, 1}},
+		3: {{4, 1}},
+	}
+	const n = 3000
+	rnd := rand.New(rand.NewSource(1))
+	counts := make(map[Node]int)
+	for i := 0; i < n; i++ {
+		path, err := FindPathOpts(g, 0, 4, Options{Rand: rnd})
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[path[1]]++
+	}
+	for _, via := range []Node{1, 2, 3} {
+		if c := counts[via]; c < n/3-150 || c > n/3+150 {
+			t.Fatalf("Expected about %d paths through %d instead of %d: %v", n/3, via, c, counts)
+		}
+	}
+}
+
+func TestSearcherWarnings(t *testing.T) {
+	mp := &gridMap{grid: make([]int, 10*10), width: 10, height: 10}
+	s := Searcher{WarnInadmissible: true}
+	if _, err := s.FindPath(mp, 0, 99); err != nil {
+		t.Fatal(err)
+	}
+	if w := s.Warnings(); len(w) != 0 {
+		t.Fatalf("Expected no warnings for an admissible heuristic instead of %v", w)
+	}
+	if _, err := s.FindPath(scaledGridMap{mp, 3}, 0, 99); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Warnings()) == 0 {
+		t.Fatal("Expected warnings for a heuristic that overestimates")
+	}
+	// Warnings are only collected when asked for
+	s.WarnInadmissible = false
+	if _, err := s.FindPath(scaledGridMap{mp, 3}, 0, 99); err != nil {
+		t.Fatal(err)
+	}
+	if w := s.Warnings(); w != nil {
+		t.Fatalf("Expected no warnings instead of %v", w)
+	}
+}
+
+// countingGridMap is a gridMap with the octile distance as its
+// heuristic, which is exact on an open grid, that counts the calls to
+// HeuristicCost.
+type countingGridMap struct {
+	*gridMap
+	calls *int
+}
+
+func (g countingGridMap) HeuristicCost(start, end Node) (float64, error) {
+	*g.calls++
+	dx := abs(int(end)%g.width - int(start)%g.width)
+	dy := abs(int(end)/g.width - int(start)/g.width)
+	return float64(max(dx, dy)-min(dx, dy)) + sqrt2*float64(min(dx, dy)), nil
+}
+
+// expensiveGridMap is a countingGridMap declaring its heuristic expensive
+type expensiveGridMap struct {
+	countingGridMap
+}
+
+// HeuristicLowerBound is the Euclidean distance, which is never more than
+// the octile distance.
+func (g expensiveGridMap) HeuristicLowerBound(start, end Node) float64 {
+	h, _ := g.gridMap.HeuristicCost(start, end)
+	return h
+}
+
+func TestExpensiveHeuristic(t *testing.T) {
+	rnd := rand.New(rand.NewSource(5))
+	for i := 0; i < 20; i++ {
+		mp := randomGridMap(rnd, 40, 30, 0.25)
+		start, end := Node(rnd.Intn(len(mp.grid))), Node(rnd.Intn(len(mp.grid)))
+		mp.grid[start] = 0
+		mp.grid[end] = 0
+		var calls, lazyCalls int
+		_, cost, err := FindPathCost(countingGridMap{mp, &calls}, start, end)
+		path, lazyCost, lazyErr := FindPathCost(expensiveGridMap{countingGridMap{mp, &lazyCalls}}, start, end)
+		if !sameError(err, lazyErr) {
+			t.Fatalf("Expected error %v instead of %v", err, lazyErr)
+		}
+		if err != nil {
+			continue
+		}
+		if math.Abs(cost-lazyCost) > 1e-9 {
+			t.Fatalf("Expected cost %f instead of %f", cost, lazyCost)
+		}
+		if c := gridPathCost(t, mp, path); math.Abs(c-cost) > 1e-9 {
+			t.Fatalf("Expected the path to cost %f instead of %f", cost, c)
+		}
+		if lazyCalls > calls {
+			t.Fatalf("Expected at most %d calls to HeuristicCost instead of %d", calls, lazyCalls)
+		}
+	}
+}
+
+// BenchmarkExpensiveHeuristic reports the calls to HeuristicCost with
+// and without deferring them.
+func BenchmarkExpensiveHeuristic(b *testing.B) {
+	mp := randomGridMap(rand.New(rand.NewSource(1)), 200, 200, 0.1)
+	mp.grid[0] = 0
+	mp.grid[len(mp.grid)-1] = 0
+	for _, lazy := range []bool{false, true} {
+		name := "Eager"
+		if lazy {
+			name = "Lazy"
+		}
+		b.Run(name, func(b *testing.B) {
+			var calls int
+			var g Graph = countingGridMap{mp, &calls}
+			if lazy {
+				g = expensiveGridMap{countingGridMap{mp, &calls}}
+			}
+			for i := 0; i < b.N; i++ {
+				if _, err := FindPath(g, 0, Node(len(mp.grid)-1)); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(calls)/float64(b.N), "heuristic/op")
+		})
+	}
+}
+
+// decicellGridMap is a gridMap with edge costs in tenths of a cell while
+// its heuristic still counts cells
+type decicellGridMap struct {
+	*gridMap
+}
+
+func (g decicellGridMap) Neighbors(node Node, edges []Edge) ([]Edge, error) {
+	start := len(edges)
+	edges, err := g.gridMap.Neighbors(node, edges)
+	for i := start; i < len(edges); i++ {
+		edges[i].Cost *= 10
+	}
+	return edges, err
+}
+
+func TestCostScale(t *testing.T) {
+	mp := randomGridMap(rand.New(rand.NewSource(1)), 50, 50, 0.2)
+	start, end := Node(0), Node(len(mp.grid)-1)
+	mp.grid[start] = 0
+	mp.grid[end] = 0
+	_, expected, err := FindPathCost(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := decicellGridMap{mp}
+	var expanded [2]int
+	for i, scale := range []float64{0, 10} {
+		var stats Stats
+		cfg := Options{CostScale: scale}.config()
+		cfg.stats = &stats
+		path, cost, err := findPath(g, start, end, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(cost-10*expected) > 1e-9 || math.Abs(10*gridPathCost(t, mp, path)-cost) > 1e-9 {
+			t.Fatalf("Expected cost %f with a scale of %f instead of %f", 10*expected, scale, cost)
+		}
+		expanded[i] = stats.NodesExpanded
+	}
+	if expanded[1] >= expanded[0] {
+		t.Fatalf("Expected scaling the heuristic to expand fewer than %d nodes instead of %d", expanded[0], expanded[1])
+	}
+}
+
+func TestBlocked(t *testing.T) {
+	// A wall that can be passed at (3, 0) or further away at (3, 4)
+	mp := &gridMap{
+		grid: []int{
+			0, 0, 0, 0, 0, 0, 0,
+			0, 0, 0, 1, 0, 0, 0,
+			0, 0, 0, 1, 0, 0, 0,
+			0, 0, 0, 1, 0, 0, 0,
+			0, 0, 0, 0, 0, 0, 0,
+		},
+		width:  7,
+		height: 5,
+	}
+	start, end := Node(0), Node(6)
+	_, cost, err := FindPathCost(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gap := Node(3)
+	path, err := FindPathOpts(mp, start, end, Options{BlockedNodes: map[Node]bool{gap: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	detour := gridPathCost(t, mp, path)
+	if detour <= cost {
+		t.Fatalf("Expected a detour costing more than %f instead of %f", cost, detour)
+	}
+	for _, n := range path {
+		if n == gap {
+			t.Fatalf("Expected the path %v to avoid the blocked node", path)
+		}
+	}
+	// Blocking the edges into the gap has the same effect
+	blocked := map[[2]Node]bool{{2, gap}: true, {9, gap}: true, {11, gap}: true}
+	edgePath, err := FindPathOpts(mp, start, end, Options{BlockedEdges: blocked})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := gridPathCost(t, mp, edgePath); c != detour {
+		t.Fatalf("Expected a detour costing %f instead of %f", detour, c)
+	}
+	// The graph itself isn't changed
+	if _, c, err := FindPathCost(mp, start, end); err != nil || c != cost {
+		t.Fatalf("Expected the unblocked path to cost %f instead of %f (%v)", cost, c, err)
+	}
+	if _, err := FindPathOpts(mp, start, end, Options{BlockedNodes: map[Node]bool{end: true}}); !errors.Is(err, ErrImpossible) {
+		t.Fatalf("Expected ErrImpossible with the end blocked instead of %v", err)
+	}
+}
+
+func TestFindPathFrames(t *testing.T) {
+	mp := randomGridMap(rand.New(rand.NewSource(1)), 30, 30, 0.2)
+	start, end := Node(0), Node(len(mp.grid)-1)
+	mp.grid[start] = 0
+	mp.grid[end] = 0
+	expected, stats, err := FindPathStats(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, frames, err := FindPathFrames(mp, start, end, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, expected) {
+		t.Fatalf("Expected %v instead of %v", expected, path)
+	}
+	if n := stats.NodesExpanded/10 + 1; len(frames) != n {
+		t.Fatalf("Expected %d frames instead of %d", n, len(frames))
+	}
+	for i, f := range frames[:len(frames)-1] {
+		if f.Expanded != 10*(i+1) || len(f.Closed) != f.Expanded {
+			t.Fatalf("Expected frame %d to have %d nodes expanded and closed instead of %d and %d", i, 10*(i+1), f.Expanded, len(f.Closed))
+		}
+		if !slices.Contains(f.Closed, f.Current) || slices.Contains(f.Open, f.Current) {
+			t.Fatalf("Expected the current node %d of frame %d to be closed", f.Current, i)
+		}
+		if !slices.IsSorted(f.Open) || !slices.IsSorted(f.Closed) {
+			t.Fatalf("Expected the nodes of frame %d to be sorted", i)
+		}
+	}
+	last := frames[len(frames)-1]
+	if last.Current != end || !slices.Contains(last.Closed, end) {
+		t.Fatalf("Expected the final frame to have closed the end instead of %+v", last)
+	}
+
+	// Frames are also returned when there's no path
+	mp.grid[end] = 1
+	if _, frames, err := FindPathFrames(mp, start, end, 0); !errors.Is(err, ErrImpossible) || len(frames) == 0 {
+		t.Fatalf("Expected ErrImpossible and frames instead of %v and %d frames", err, len(frames))
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	mp := randomGridMap(rand.New(rand.NewSource(1)), 30, 30, 0.2)
+	start, end := Node(0), Node(len(mp.grid)-1)
+	mp.grid[start] = 0
+	mp.grid[end] = 0
+	if _, err := Snapshot(mp); err != ErrNotDense {
+		t.Fatalf("Expected ErrNotDense instead of %v", err)
+	}
+	snap, err := Snapshot(denseGridMap{mp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := FindPath(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Walling off the end afterwards doesn't change the snapshot
+	mp.grid[end-1] = 1
+	mp.grid[end-Node(mp.width)] = 1
+	mp.grid[end-Node(mp.width)-1] = 1
+	if _, err := FindPath(mp, start, end); !errors.Is(err, ErrImpossible) {
+		t.Fatalf("Expected ErrImpossible instead of %v", err)
+	}
+	path, err := FindPath(snap, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, expected) {
+		t.Fatalf("Expected %v instead of %v", expected, path)
+	}
+	if edges, _ := snap.Neighbors(Node(len(mp.grid)), nil); len(edges) != 0 {
+		t.Fatalf("Expected no edges for a node outside of the graph instead of %v", edges)
+	}
+}
+
+func TestNewLinkGraph(t *testing.T) {
+	// A one-way loop can only be followed the long way around
+	loop := NewLinkGraph([]Link{
+		{From: 0, To: 1, Cost: 1, OneWay: true},
+		{From: 1, To: 2, Cost: 1, OneWay: true},
+		{From: 2, To: 3, Cost: 1, OneWay: true},
+		{From: 3, To: 0, Cost: 1, OneWay: true},
+	}, nil, nil)
+	path, err := FindPath(loop, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, []Node{1, 2, 3, 0}) {
+		t.Fatalf("Expected [1 2 3 0] instead of %v", path)
+	}
+
+	// A block with a road into its corner at 0
+	links := []Link{
+		{From: 4, To: 0, Cost: 1},
+		{From: 0, To: 1, Cost: 1},
+		{From: 1, To: 2, Cost: 1},
+		{From: 2, To: 3, Cost: 1},
+		{From: 3, To: 0, Cost: 2},
+	}
+	path, err = FindPath(NewLinkGraph(links, nil, nil), 4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, []Node{4, 0, 1}) {
+		t.Fatalf("Expected [4 0 1] instead of %v", path)
+	}
+	// Without the turn from 4 onto 1 at 0 the way is around the block
+	restricted := NewLinkGraph(links, []TurnRestriction{{From: 4, Via: 0, To: 1}}, nil)
+	path, err = FindPath(restricted, 4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, []Node{4, 0, 3, 2, 1}) {
+		t.Fatalf("Expected [4 0 3 2 1] instead of %v", path)
+	}
+	// The turn is only forbidden when arriving from 4
+	path, err = FindPath(restricted, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalPaths(path, []Node{0, 1}) {
+		t.Fatalf("Expected [0 1] instead of %v", path)
+	}
+	if cost, err := VerifyPath(restricted, []Node{4, 0, 3, 2, 1}); err != nil || cost != 5 {
+		t.Fatalf("Expected a cost of 5 instead of %f (%v)", cost, err)
+	}
+}
+
+func TestFindPathSeeded(t *testing.T) {
+	mp := randomGridMap(rand.New(rand.NewSource(1)), 50, 50, 0.2)
+	start, end := Node(0), Node(len(mp.grid)-1)
+	mp.grid[start] = 0
+	mp.grid[end] = 0
+	var stats Stats
+	expected, cost, err := findPath(mp, start, end, config{stats: &stats})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Seed the first three quarters of the optimal path
+	var seeds []SeededNode
+	parent, c := Node(-1), 0.0
+	for i, n := range expected[:len(expected)*3/4] {
+		if i > 0 {
+			c += gridPathCost(t, mp, expected[i-1:i+1])
+		}
+		seeds = append(seeds, SeededNode{Node: n, Cost: c, Parent: parent})
+		parent = n
+	}
+	var seededStats Stats
+	path, seededCost, err := findPathSeeded(mp, seeds, end, config{stats: &seededStats})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(seededCost-cost) > 1e-9 || math.Abs(gridPathCost(t, mp, path)-cost) > 1e-9 {
+		t.Fatalf("Expected a path costing %f instead of %f", cost, seededCost)
+	}
+	if path[0] != start || path[len(path)-1] != end {
+		t.Fatalf("Expected a path from %d to %d instead of %v", start, end, path)
+	}
+	if seededStats.NodesExpanded > stats.NodesExpanded {
+		t.Fatalf("Expected at most %d nodes expanded instead of %d", stats.NodesExpanded, seededStats.NodesExpanded)
+	}
+	if path, err := FindPathSeeded(mp, seeds, end); err != nil || !equalPaths(path, expected) {
+		t.Fatalf("Expected %v instead of %v (%v)", expected, path, err)
+	}
+
+	// The cheapest path leaves the plan before its tip
+	g := adjGraph{
+		0: {{1, 1}, {2, 1}},
+		2: {{3, 1}},
+	}
+	if path, err := FindPathSeeded(g, []SeededNode{{0, 0, -1}, {1, 1, 0}}, 3); err != nil || !equalPaths(path, []Node{0, 2, 3}) {
+		t.Fatalf("Expected [0 2 3] instead of %v (%v)", path, err)
+	}
+
+	invalid := [][]SeededNode{
+		{{Node: 1, Parent: -1}, {Node: 1, Parent: 1}},
+		{{Node: 1, Parent: -1}, {Node: 2, Parent: 3}},
+		{{Node: 1, Parent: 2}, {Node: 2, Parent: 1}},
+		{{Node: 1, Parent: -1}, {Node: 2, Parent: 3}, {Node: 3, Parent: 2}},
+	}
+	for _, seeds := range invalid {
+		if _, err := FindPathSeeded(mp, seeds, end); err != ErrInvalidSeed {
+			t.Fatalf("Expected ErrInvalidSeed for %v instead of %v", seeds, err)
+		}
+	}
+	if _, err := FindPathSeeded(mp, []SeededNode{{Node: 1, Cost: -1, Parent: -1}}, end); !errors.Is(err, ErrInvalidCost) {
+		t.Fatalf("Expected ErrInvalidCost instead of %v", err)
+	}
+}
+
+func TestMaxHeuristic(t *testing.T) {
+	mp := revGridMap{randomGridMap(rand.New(rand.NewSource(3)), 30, 30, 0.2)}
+	corners := []Node{0, 29, 30 * 29, 30*30 - 1}
+	for _, n := range corners {
+		mp.grid[n] = 0
+	}
+	var lm Landmarks
+	if err := lm.Precompute(mp, corners); err != nil {
+		t.Fatal(err)
+	}
+	euclidean := func(a, b Node) float64 {
+		h, _ := mp.gridMap.HeuristicCost(a, b)
+		return h
+	}
+	heuristics := []struct {
+		name string
+		fn   func(a, b Node) float64
+	}{
+		{"euclidean", euclidean},
+		{"landmarks", lm.Heuristic},
+		{"max", MaxHeuristic(euclidean, lm.Heuristic)},
+	}
+	rnd := rand.New(rand.NewSource(4))
+	expanded := make([]int, len(heuristics))
+	for i := 0; i < 50; i++ {
+		start, end := Node(rnd.Intn(len(mp.grid))), Node(rnd.Intn(len(mp.grid)))
+		if mp.grid[start] != 0 || mp.grid[end] != 0 {
+			continue
+		}
+		_, expected, err := FindPathCost(mp, start, end)
+		if errors.Is(err, ErrImpossible) {
+			continue
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		for j, h := range heuristics {
+			g := NewGraphFunc(mp.Neighbors, func(a, b Node) (float64, error) {
+				return h.fn(a, b), nil
+			})
+			var stats Stats
+			_, cost, err := findPath(g, start, end, config{stats: &stats})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(cost-expected) > 1e-9 {
+				t.Fatalf("Expected cost %f with the %s heuristic instead of %f", expected, h.name, cost)
+			}
+			expanded[j] += stats.NodesExpanded
+		}
+	}
+	t.Logf("expanded: euclidean %d, landmarks %d, max %d", expanded[0], expanded[1], expanded[2])
+	if expanded[2] >= expanded[0] || expanded[2] >= expanded[1] {
+		t.Fatalf("Expected the max to expand fewer nodes than euclidean (%d) and landmarks (%d) instead of %d", expanded[0], expanded[1], expanded[2])
+	}
+	if h := MaxHeuristic()(0, 1); h != 0 {
+		t.Fatalf("Expected 0 without heuristics instead of %f", h)
+	}
+}
+
+func TestGreedyFallback(t *testing.T) {
+	mp := randomGridMap(rand.New(rand.NewSource(1)), 50, 50, 0.2)
+	start, end := Node(0), Node(len(mp.grid)-1)
+	mp.grid[start] = 0
+	mp.grid[end] = 0
+	_, stats, err := FindPathStats(mp, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	budget := stats.NodesExpanded / 2
+	if _, err := FindPathOpts(mp, start, end, Options{MaxNodes: budget}); err != ErrBudgetExceeded {
+		t.Fatalf("Expected ErrBudgetExceeded instead of %v", err)
+	}
+	cases := []Options{
+		{MaxNodes: budget, GreedyAfterNodes: budget / 4},
+		// Greedy from the start
+		{MaxNodes: budget, GreedyAfterTime: time.Nanosecond},
+	}
+	for _, opts := range cases {
+		path, err := FindPathOpts(mp, start, end, opts)
+		if err != ErrGreedyPath {
+			t.Fatalf("Expected ErrGreedyPath with %+v instead of %v", opts, err)
+		}
+		if path[0] != start || path[len(path)-1] != end {
+			t.Fatalf("Expected a path from %d to %d instead of %v", start, end, path)
+		}
+		// Also checks that every step is to a neighbor
+		gridPathCost(t, mp, path)
+	}
+	// A search that finishes before the switch is optimal
+	if _, err := FindPathOpts(mp, start, end, Options{GreedyAfterNodes: stats.NodesExpanded + 1}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPackXY(t *testing.T) {
+	const w, h, d = 7, 5, 3
+	for z := 0; z < d; z++ {
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				node := PackXY(x, y, w)
+				if node != Node(y*w+x) {
+					t.Fatalf("PackXY(%d, %d) = %d, expected %d", x, y, node, y*w+x)
+				}
+				if ux, uy := UnpackXY(node, w); ux != x || uy != y {
+					t.Fatalf("UnpackXY(%d) = (%d, %d), expected (%d, %d)", node, ux, uy, x, y)
+				}
+				node = PackXYZ(x, y, z, w, h)
+				if node != Node((z*h+y)*w+x) {
+					t.Fatalf("PackXYZ(%d, %d, %d) = %d, expected %d", x, y, z, node, (z*h+y)*w+x)
+				}
+				checked, err := PackXYZChecked(x, y, z, w, h, d)
+				if err != nil || checked != node {
+					t.Fatalf("PackXYZChecked(%d, %d, %d) = %d, %v, expected %d", x, y, z, checked, err, node)
+				}
+				ux, uy, uz, err := UnpackXYZChecked(node, w, h, d)
+				if err != nil || ux != x || uy != y || uz != z {
+					t.Fatalf("UnpackXYZChecked(%d) = (%d, %d, %d), %v, expected (%d, %d, %d)", node, ux, uy, uz, err, x, y, z)
+				}
+			}
+		}
+	}
+	// Rows larger than 32 bits worth of cells
+	const big = 1 << 20
+	node := PackXY(big-1, big-1, big)
+	if x, y := UnpackXY(node, big); x != big-1 || y != big-1 {
+		t.Fatalf("UnpackXY(%d) = (%d, %d), expected (%d, %d)", node, x, y, big-1, big-1)
+	}
+
+	for _, c := range [][4]int{{-1, 0, w, h}, {0, -1, w, h}, {w, 0, w, h}, {0, h, w, h}, {0, 0, 0, h}, {0, 0, math.MaxInt64, math.MaxInt64}} {
+		if _, err := PackXYChecked(c[0], c[1], c[2], c[3]); err != ErrOutOfRange {
+			t.Errorf("PackXYChecked(%v) expected ErrOutOfRange instead of %v", c, err)
+		}
+	}
+	for _, node := range []Node{-1, w * h} {
+		if _, _, err := UnpackXYChecked(node, w, h); err != ErrOutOfRange {
+			t.Errorf("UnpackXYChecked(%d) expected ErrOutOfRange instead of %v", node, err)
+		}
+	}
+	if _, err := PackXYZChecked(0, 0, d, w, h, d); err != ErrOutOfRange {
+		t.Errorf("PackXYZChecked expected ErrOutOfRange instead of %v", err)
+	}
+	if _, _, _, err := UnpackXYZChecked(w*h*d, w, h, d); err != ErrOutOfRange {
+		t.Errorf("UnpackXYZChecked expected ErrOutOfRange instead of %v", err)
+	}
+}
+
+// offsetGridMap adds a constant to every heuristic cost, so it's wrong at
+// the end.
+type offsetGridMap struct {
+	*gridMap
+	offset float64
+}
+
+func (g offsetGridMap) HeuristicCost(start, end Node) (float64, error) {
+	h, err := g.gridMap.HeuristicCost(start, end)
+	return h + g.offset, err
+}
+
+func TestCheckGoalHeuristic(t *testing.T) {
+	mp := randomGridMap(rand.New(rand.NewSource(1)), 50, 50, 0.2)
+	start, end := Node(0), Node(len(mp.grid)-1)
+	mp.grid[start] = 0
+	mp.grid[end] = 0
+	opts := Options{CheckGoalHeuristic: true}
+	if _, err := FindPathOpts(mp, start, end, opts); err != nil {
+		t.Fatal(err)
+	}
+	bad := offsetGridMap{mp, 0.5}
+	if _, err := FindPathOpts(bad, start, end, opts); err != ErrBadHeuristic {
+		t.Fatalf("Expected ErrBadHeuristic instead of %v", err)
+	}
+	// Without the check the search goes ahead
+	if _, err := FindPathOpts(bad, start, end, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	opts.IgnoreHeuristic = true
+	if _, err := FindPathOpts(bad, start, end, opts); err != nil {
+		t.Fatal(err)
 	}
 }
@@ -0,0 +1,72 @@
+package astar
+
+// FindPathGeneral finds the cheapest path from start to end in a graph
+// that may have edges with negative costs, which A* and Dijkstra's
+// algorithm can't handle. It uses the Bellman-Ford algorithm in its
+// queue based form (SPFA), which visits every node reachable from start
+// at least once and possibly many times, so it's much slower than
+// FindPath and the part of the graph reachable from start must be
+// finite. The heuristic is not used. If a cycle with a negative total
+// cost can be reached from start, even one not on the way to end,
+// ErrNegativeCycle is returned.
+func FindPathGeneral(mp Graph, start, end Node) ([]Node, error) {
+	type record struct {
+		cost   float64
+		parent Node
+		length int // edges on the path from start
+		queued bool
+	}
+	nodes := map[Node]*record{start: {parent: -1, queued: true}}
+	queue := []Node{start}
+	var edges []Edge
+	expanded := 0
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		r := nodes[node]
+		r.queued = false
+		expanded++
+		var err error
+		edges, err = mp.Neighbors(node, edges[:0])
+		if err != nil {
+			return nil, err
+		}
+		for _, edge := range edges {
+			// Negative costs are fine but NaN isn't
+			if edge.Cost != edge.Cost {
+				return nil, &InvalidCostError{From: node, To: edge.Node, Cost: edge.Cost}
+			}
+			cost := r.cost + edge.Cost
+			next := nodes[edge.Node]
+			if next == nil {
+				next = &record{}
+				nodes[edge.Node] = next
+			} else if cost >= next.cost {
+				continue
+			}
+			next.cost = cost
+			next.parent = node
+			next.length = r.length + 1
+			// A path with more edges than there are nodes visits some
+			// node twice, and since every update lowers the cost the
+			// cycle must be negative.
+			if next.length >= len(nodes) {
+				return nil, ErrNegativeCycle
+			}
+			if !next.queued {
+				next.queued = true
+				queue = append(queue, edge.Node)
+			}
+		}
+	}
+	r := nodes[end]
+	if r == nil {
+		return nil, errNoPath([]Node{start}, goals{end}, expanded)
+	}
+	path := make([]Node, r.length+1)
+	for n, i := end, r.length; i >= 0; i-- {
+		path[i] = n
+		n = nodes[n].parent
+	}
+	return path, nil
+}
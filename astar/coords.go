@@ -0,0 +1,93 @@
+package astar
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrOutOfRange is returned by the checked coordinate packing functions
+// when coordinates are outside of the grid or the grid has more cells
+// than there are nodes.
+var ErrOutOfRange = errors.New("astar: coordinates out of range")
+
+// PackXY returns the node for the cell at (x, y) of a grid width cells
+// wide, numbering the cells row by row from 0 as y*width+x. The
+// coordinates aren't checked, see PackXYChecked.
+func PackXY(x, y, width int) Node {
+	return Node(y)*Node(width) + Node(x)
+}
+
+// UnpackXY returns the coordinates of the cell of a node numbered by
+// PackXY. The node must not be negative.
+func UnpackXY(node Node, width int) (x, y int) {
+	return int(node % Node(width)), int(node / Node(width))
+}
+
+// PackXYZ returns the node for the cell at (x, y, z) of a grid width
+// cells wide and height cells high, numbering the cells row by row and
+// layer by layer from 0 as (z*height+y)*width+x. The coordinates aren't
+// checked, see PackXYZChecked.
+func PackXYZ(x, y, z, width, height int) Node {
+	return (Node(z)*Node(height)+Node(y))*Node(width) + Node(x)
+}
+
+// UnpackXYZ returns the coordinates of the cell of a node numbered by
+// PackXYZ. The node must not be negative.
+func UnpackXYZ(node Node, width, height int) (x, y, z int) {
+	x, yz := UnpackXY(node, width)
+	y, z = yz%height, yz/height
+	return x, y, z
+}
+
+// cells returns the number of cells of a grid with the given dimensions
+// or false if any is not positive or there are more cells than nodes.
+func cells(dims ...int) (Node, bool) {
+	n := Node(1)
+	for _, d := range dims {
+		if d <= 0 || Node(d) > math.MaxInt64/n {
+			return 0, false
+		}
+		n *= Node(d)
+	}
+	return n, true
+}
+
+// PackXYChecked is like PackXY for a grid height cells high but returns
+// ErrOutOfRange if (x, y) isn't a cell of the grid or the grid has more
+// cells than there are nodes.
+func PackXYChecked(x, y, width, height int) (Node, error) {
+	if _, ok := cells(width, height); !ok || x < 0 || x >= width || y < 0 || y >= height {
+		return 0, ErrOutOfRange
+	}
+	return PackXY(x, y, width), nil
+}
+
+// UnpackXYChecked is like UnpackXY for a grid height cells high but
+// returns ErrOutOfRange if the node isn't a cell of the grid.
+func UnpackXYChecked(node Node, width, height int) (x, y int, err error) {
+	if n, ok := cells(width, height); !ok || node < 0 || node >= n {
+		return 0, 0, ErrOutOfRange
+	}
+	x, y = UnpackXY(node, width)
+	return x, y, nil
+}
+
+// PackXYZChecked is like PackXYZ for a grid depth layers deep but returns
+// ErrOutOfRange if (x, y, z) isn't a cell of the grid or the grid has
+// more cells than there are nodes.
+func PackXYZChecked(x, y, z, width, height, depth int) (Node, error) {
+	if _, ok := cells(width, height, depth); !ok || x < 0 || x >= width || y < 0 || y >= height || z < 0 || z >= depth {
+		return 0, ErrOutOfRange
+	}
+	return PackXYZ(x, y, z, width, height), nil
+}
+
+// UnpackXYZChecked is like UnpackXYZ for a grid depth layers deep but
+// returns ErrOutOfRange if the node isn't a cell of the grid.
+func UnpackXYZChecked(node Node, width, height, depth int) (x, y, z int, err error) {
+	if n, ok := cells(width, height, depth); !ok || node < 0 || node >= n {
+		return 0, 0, 0, ErrOutOfRange
+	}
+	x, y, z = UnpackXYZ(node, width, height)
+	return x, y, z, nil
+}
@@ -0,0 +1,146 @@
+package astar
+
+import (
+	"errors"
+	"math"
+)
+
+// NeighborGraph is a graph that can only enumerate the neighbors of
+// a node and has no heuristic.
+type NeighborGraph interface {
+	Neighbors(node Node, edges []Edge) ([]Edge, error)
+}
+
+type zeroHeuristic struct {
+	NeighborGraph
+}
+
+func (zeroHeuristic) HeuristicCost(start, end Node) (float64, error) {
+	return 0, nil
+}
+
+// Dijkstra finds the optimal path through the graph from start to end
+// using a constant zero heuristic, which reduces A* to Dijkstra's
+// algorithm. Since the graph is wrapped, optional interfaces such as
+// Debug and PossiblePath are not used.
+func Dijkstra(mp NeighborGraph, start, end Node) ([]Node, error) {
+	return FindPath(zeroHeuristic{mp}, start, end)
+}
+
+// undirectedGraph is an Undirected graph whose incoming edges are its
+// outgoing edges.
+type undirectedGraph struct {
+	Graph
+}
+
+func (g undirectedGraph) ReverseNeighbors(node Node, edges []Edge) ([]Edge, error) {
+	return g.Neighbors(node, edges)
+}
+
+// reverse returns mp as a ReverseGraph if it is one or is Undirected.
+func reverse(mp Graph) (ReverseGraph, error) {
+	if rg, ok := mp.(ReverseGraph); ok {
+		return rg, nil
+	}
+	if _, ok := mp.(Undirected); ok {
+		return undirectedGraph{mp}, nil
+	}
+	return nil, ErrNotReversible
+}
+
+// reversedGraph follows the incoming edges of a ReverseGraph with no
+// heuristic.
+type reversedGraph struct {
+	ReverseGraph
+}
+
+func (g reversedGraph) Neighbors(node Node, edges []Edge) ([]Edge, error) {
+	return g.ReverseNeighbors(node, edges)
+}
+
+func (reversedGraph) HeuristicCost(start, end Node) (float64, error) {
+	return 0, nil
+}
+
+// CostField returns the cost of the cheapest path from every node that
+// can reach goal to goal, including goal itself at a cost of 0. It runs
+// Dijkstra's algorithm outward from goal over the incoming edges until
+// every reachable node has been visited. Following any neighbor with a
+// lower cost leads to the goal along a cheapest path, which lets many
+// agents share one search for a common destination. The graph must be a
+// ReverseGraph or Undirected, otherwise ErrNotReversible is returned.
+func CostField(mp Graph, goal Node) (map[Node]float64, error) {
+	rg, err := reverse(mp)
+	if err != nil {
+		return nil, err
+	}
+	field := make(map[Node]float64)
+	_, _, err = findPathGoals(reversedGraph{rg}, []Node{goal}, nil, config{explored: field})
+	if err != nil && err != ErrImpossible {
+		return nil, err
+	}
+	return field, nil
+}
+
+// Reachable returns every node that can be reached from start for a
+// cost of at most maxCost, including start itself, mapped to the cost
+// of the cheapest path to it. It runs Dijkstra's algorithm outward from
+// start, ignoring the heuristic. A maxCost of +Inf finds every node
+// reachable from start at all.
+func Reachable(mp Graph, start Node, maxCost float64) (map[Node]float64, error) {
+	reached := make(map[Node]float64)
+	if maxCost < 0 {
+		return reached, nil
+	}
+	// Paths must cost less than the config's maxCost
+	limit := math.Nextafter(maxCost, math.Inf(1))
+	_, _, err := findPathGoals(mp, []Node{start}, nil, config{explored: reached, maxCost: limit})
+	if err != nil && err != ErrImpossible {
+		return nil, err
+	}
+	return reached, nil
+}
+
+// IsReachable reports whether there's any path from start to end. The
+// search stops as soon as it reaches end, so when it can it's cheaper
+// than finding every node reachable from start.
+func IsReachable(mp Graph, start, end Node) (bool, error) {
+	if start == end {
+		return true, nil
+	}
+	_, _, err := search(mp, []Node{start}, goals{end}, config{})
+	if errors.Is(err, ErrImpossible) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// CostMatrix returns the cost of the cheapest path between every pair of
+// nodes, where matrix[i][j] is the cost from nodes[i] to nodes[j] and is
+// +Inf if there's no path. It runs Dijkstra's algorithm once from each
+// node over everything reachable from it, ignoring the heuristic and
+// reusing the memory of the previous run. For N nodes in a graph of V
+// nodes and E edges that's O(N·(E+V log V)), where calling FindPath for
+// every pair would run N² searches.
+func CostMatrix(mp Graph, nodes []Node) ([][]float64, error) {
+	matrix := make([][]float64, len(nodes))
+	explored := make(map[Node]float64)
+	var s Searcher
+	for i, start := range nodes {
+		clear(explored)
+		_, _, err := search(mp, []Node{start}, nil, config{state: s.reset(), explored: explored})
+		if err != nil && err != ErrImpossible {
+			return nil, err
+		}
+		row := make([]float64, len(nodes))
+		for j, end := range nodes {
+			if c, ok := explored[end]; ok {
+				row[j] = c
+			} else {
+				row[j] = math.Inf(1)
+			}
+		}
+		matrix[i] = row
+	}
+	return matrix, nil
+}
@@ -0,0 +1,302 @@
+package astar
+
+import (
+	"math"
+)
+
+type dsNode struct {
+	node  Node
+	g     float64
+	rhs   float64
+	key   [2]float64
+	index int // index in the queue or -1 if not queued
+}
+
+// DStarLite incrementally replans the optimal path from a start to a
+// goal as edge costs change, reusing as much of the previous search as
+// possible. It searches backwards from the goal so the graph must be
+// able to enumerate incoming edges. The heuristic HeuristicCost(a, b)
+// must be admissible and consistent.
+//
+// A DStarLite is not safe for concurrent use by multiple goroutines.
+type DStarLite struct {
+	mp    ReverseGraph
+	start Node
+	goal  Node
+	km    float64
+	nodes map[Node]*dsNode
+	queue []*dsNode
+	out   map[Node]map[Node]float64 // overridden edge costs by source
+	in    map[Node]map[Node]float64 // overridden edge costs by destination
+	edges []Edge
+	err   error
+	stats Stats
+}
+
+// NewDStarLite returns a planner for the graph. Init must be called
+// before planning.
+func NewDStarLite(mp ReverseGraph) *DStarLite {
+	return &DStarLite{mp: mp}
+}
+
+// Init resets the planner to find paths from start to goal. Any edge
+// cost changes from previous calls to UpdateEdge are discarded.
+func (d *DStarLite) Init(start, goal Node) {
+	d.start = start
+	d.goal = goal
+	d.km = 0
+	d.nodes = make(map[Node]*dsNode)
+	d.queue = d.queue[:0]
+	d.out = make(map[Node]map[Node]float64)
+	d.in = make(map[Node]map[Node]float64)
+	d.err = nil
+	g := d.get(goal)
+	g.rhs = 0
+	d.push(g, [2]float64{d.heuristic(goal), 0})
+}
+
+// SetStart moves the start of the path, such as when a robot following
+// the previous plan has moved to the next node.
+func (d *DStarLite) SetStart(start Node) {
+	d.km += d.heuristicBetween(d.start, start)
+	d.start = start
+}
+
+// UpdateEdge sets the cost of the edge from one node to another,
+// overriding the cost given by the graph. A cost of +Inf removes the
+// edge. The change takes effect on the next call to Plan. Since Init
+// discards changes, calls before the first call to Init are ignored.
+func (d *DStarLite) UpdateEdge(from, to Node, newCost float64) {
+	if d.nodes == nil {
+		return
+	}
+	if d.out[from] == nil {
+		d.out[from] = make(map[Node]float64)
+	}
+	if d.in[to] == nil {
+		d.in[to] = make(map[Node]float64)
+	}
+	d.out[from][to] = newCost
+	d.in[to][from] = newCost
+	d.updateVertex(d.get(from))
+}
+
+// Plan returns the optimal path from the start to the goal given all
+// edge changes so far.
+func (d *DStarLite) Plan() ([]Node, error) {
+	if d.nodes == nil {
+		return nil, ErrImpossible
+	}
+	d.stats = Stats{}
+	d.computeShortestPath()
+	if d.err != nil {
+		return nil, d.err
+	}
+	s := d.get(d.start)
+	if math.IsInf(s.g, 1) {
+		return nil, ErrImpossible
+	}
+	// Follow the cheapest successors from the start to the goal. The
+	// path can't be longer than the number of nodes seen.
+	path := []Node{d.start}
+	for n := d.start; n != d.goal; {
+		if len(path) > len(d.nodes) {
+			return nil, ErrImpossible
+		}
+		best := math.Inf(1)
+		var next Node
+		if err := d.successors(n, func(to Node, cost float64) {
+			if c := cost + d.get(to).g; c < best {
+				best = c
+				next = to
+			}
+		}); err != nil {
+			return nil, err
+		}
+		if math.IsInf(best, 1) {
+			return nil, ErrImpossible
+		}
+		path = append(path, next)
+		n = next
+	}
+	return path, nil
+}
+
+// Stats returns statistics about the work done by the last call to Plan.
+func (d *DStarLite) Stats() Stats {
+	return d.stats
+}
+
+func (d *DStarLite) get(n Node) *dsNode {
+	dn := d.nodes[n]
+	if dn == nil {
+		dn = &dsNode{node: n, g: math.Inf(1), rhs: math.Inf(1), index: -1}
+		d.nodes[n] = dn
+	}
+	return dn
+}
+
+func (d *DStarLite) heuristicBetween(a, b Node) float64 {
+	h, err := d.mp.HeuristicCost(a, b)
+	if err != nil && d.err == nil {
+		d.err = err
+	}
+	return h
+}
+
+func (d *DStarLite) heuristic(n Node) float64 {
+	return d.heuristicBetween(d.start, n)
+}
+
+func (d *DStarLite) calculateKey(n *dsNode) [2]float64 {
+	m := math.Min(n.g, n.rhs)
+	return [2]float64{m + d.heuristic(n.node) + d.km, m}
+}
+
+// successors calls fn for each outgoing edge of n applying overrides.
+func (d *DStarLite) successors(n Node, fn func(to Node, cost float64)) error {
+	var err error
+	d.edges, err = d.mp.Neighbors(n, d.edges[:0])
+	if err != nil {
+		return err
+	}
+	over := d.out[n]
+	for _, e := range d.edges {
+		if _, ok := over[e.Node]; !ok {
+			fn(e.Node, e.Cost)
+		}
+	}
+	for to, c := range over {
+		fn(to, c)
+	}
+	return nil
+}
+
+// predecessors calls fn for each incoming edge of n applying overrides.
+func (d *DStarLite) predecessors(n Node, fn func(from Node, cost float64)) error {
+	var err error
+	d.edges, err = d.mp.ReverseNeighbors(n, d.edges[:0])
+	if err != nil {
+		return err
+	}
+	over := d.in[n]
+	for _, e := range d.edges {
+		if _, ok := over[e.Node]; !ok {
+			fn(e.Node, e.Cost)
+		}
+	}
+	for from, c := range over {
+		fn(from, c)
+	}
+	return nil
+}
+
+func (d *DStarLite) updateVertex(u *dsNode) {
+	if u.node != d.goal {
+		rhs := math.Inf(1)
+		if err := d.successors(u.node, func(to Node, cost float64) {
+			if c := cost + d.get(to).g; c < rhs {
+				rhs = c
+			}
+		}); err != nil && d.err == nil {
+			d.err = err
+		}
+		u.rhs = rhs
+	}
+	if u.index >= 0 {
+		d.remove(u)
+	}
+	if u.g != u.rhs {
+		d.push(u, d.calculateKey(u))
+	}
+}
+
+func (d *DStarLite) computeShortestPath() {
+	var preds []Node
+	s := d.get(d.start)
+	for len(d.queue) > 0 && d.err == nil {
+		u := d.queue[0]
+		if !keyLessApprox(u.key, d.calculateKey(s)) && s.rhs == s.g {
+			break
+		}
+		d.stats.NodesExpanded++
+		if kNew := d.calculateKey(u); keyLess(u.key, kNew) {
+			u.key = kNew
+			d.fix(u.index)
+			continue
+		}
+		// Collect the predecessors first since updateVertex reuses the
+		// edge slice.
+		preds = preds[:0]
+		if err := d.predecessors(u.node, func(from Node, cost float64) {
+			preds = append(preds, from)
+		}); err != nil {
+			d.err = err
+			return
+		}
+		if u.g > u.rhs {
+			u.g = u.rhs
+			d.remove(u)
+		} else {
+			u.g = math.Inf(1)
+			d.updateVertex(u)
+		}
+		for _, p := range preds {
+			d.updateVertex(d.get(p))
+		}
+	}
+}
+
+func keyLess(a, b [2]float64) bool {
+	return a[0] < b[0] || (a[0] == b[0] && a[1] < b[1])
+}
+
+// keyLessApprox is like keyLess but treats first components within
+// rounding error as equal. Otherwise a node whose key should tie with
+// the start's can compare greater and end the search too early.
+func keyLessApprox(a, b [2]float64) bool {
+	tol := 1e-9 * math.Max(1, math.Abs(b[0]))
+	if math.Abs(a[0]-b[0]) <= tol {
+		return a[1] < b[1]
+	}
+	return a[0] < b[0]
+}
+
+func (d *DStarLite) less(i, j int) bool {
+	return keyLess(d.queue[i].key, d.queue[j].key)
+}
+
+func (d *DStarLite) swap(i, j int) {
+	q := d.queue
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (d *DStarLite) fix(i int) {
+	heapFix(d, i, len(d.queue))
+}
+
+func (d *DStarLite) push(n *dsNode, key [2]float64) {
+	n.key = key
+	n.index = len(d.queue)
+	d.queue = append(d.queue, n)
+	heapUp(d, n.index)
+	if len(d.queue) > d.stats.MaxOpenSize {
+		d.stats.MaxOpenSize = len(d.queue)
+	}
+}
+
+func (d *DStarLite) remove(n *dsNode) {
+	i := n.index
+	last := len(d.queue) - 1
+	if i != last {
+		d.swap(i, last)
+	}
+	d.queue[last] = nil
+	d.queue = d.queue[:last]
+	if i != last {
+		d.fix(i)
+	}
+	n.index = -1
+}
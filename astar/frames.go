@@ -0,0 +1,68 @@
+package astar
+
+import (
+	"slices"
+)
+
+// Frame is a snapshot of a search taken by FindPathFrames.
+type Frame struct {
+	Expanded int    // nodes expanded so far, including Current
+	Current  Node   // node being expanded, or the end in the final frame
+	Open     []Node // nodes in the open list, sorted by ID
+	Closed   []Node // nodes already expanded, sorted by ID
+}
+
+// frameRecorder is the Debug used by FindPathFrames to take snapshots.
+type frameRecorder struct {
+	state    *state
+	next     Debug // the graph's own Debug if any
+	everyN   int
+	expanded int
+	frames   []Frame
+}
+
+func (r *frameRecorder) VisitedNode(node, parentNode Node, currentCost, predictedCost float64) {
+	if r.next != nil {
+		r.next.VisitedNode(node, parentNode, currentCost, predictedCost)
+	}
+	r.expanded++
+	if r.expanded%r.everyN == 0 {
+		r.snapshot(node)
+	}
+}
+
+func (r *frameRecorder) snapshot(current Node) {
+	f := Frame{Expanded: r.expanded, Current: current}
+	r.state.each(func(ni *OpenNode) {
+		if ni.index < 0 {
+			f.Closed = append(f.Closed, ni.node)
+		} else {
+			f.Open = append(f.Open, ni.node)
+		}
+	})
+	// With turn costs a node can be tracked more than once
+	slices.Sort(f.Open)
+	f.Open = slices.Compact(f.Open)
+	slices.Sort(f.Closed)
+	f.Closed = slices.Compact(f.Closed)
+	r.frames = append(r.frames, f)
+}
+
+// FindPathFrames is like FindPath but also returns snapshots of the open
+// and closed nodes of the search, taken as every everyN-th node is
+// expanded and once more when the search is over, so that the progress
+// of the search can be replayed, for instance to animate it. Every frame
+// lists every node the search has seen so the memory used grows quickly
+// with the number of frames; everyN values below 1 are treated as 1. The
+// frames are returned even if no path is found. If the graph implements
+// Debug it's still called.
+func FindPathFrames(mp Graph, start, end Node, everyN int) ([]Node, []Frame, error) {
+	r := &frameRecorder{
+		state:  newState(defaultCapacity(start, end)),
+		everyN: max(everyN, 1),
+	}
+	r.next, _ = mp.(Debug)
+	path, _, err := findPath(mp, start, end, config{state: r.state, debug: r})
+	r.snapshot(end)
+	return path, r.frames, err
+}
@@ -0,0 +1,130 @@
+package astar
+
+import (
+	"math"
+)
+
+type fringeNode struct {
+	node       Node
+	parent     *fringeNode
+	g, h       float64
+	prev, next *fringeNode
+	listed     bool // in the fringe
+}
+
+// fringe is a doubly linked list of nodes. The nodes before the one
+// being visited make up the later list of Fringe Search and the rest the
+// now list.
+type fringe struct {
+	head, tail *fringeNode
+}
+
+func (f *fringe) insertAfter(at, n *fringeNode) {
+	n.prev = at
+	if at == nil {
+		n.next = f.head
+		f.head = n
+	} else {
+		n.next = at.next
+		at.next = n
+	}
+	if n.next == nil {
+		f.tail = n
+	} else {
+		n.next.prev = n
+	}
+	n.listed = true
+}
+
+func (f *fringe) remove(n *fringeNode) {
+	if n.prev == nil {
+		f.head = n.next
+	} else {
+		n.prev.next = n.next
+	}
+	if n.next == nil {
+		f.tail = n.prev
+	} else {
+		n.next.prev = n.prev
+	}
+	n.prev = nil
+	n.next = nil
+	n.listed = false
+}
+
+// FindPathFringe finds the optimal path from start to end using Fringe
+// Search. Instead of keeping the open list sorted in a heap it makes
+// repeated passes over an unsorted list, expanding only nodes whose f
+// cost is within a threshold that is raised to the lowest f cost that
+// exceeded it after every pass. This uses less memory per node than
+// FindPath and no heap operations, at the cost of visiting nodes in the
+// list more than once. The heuristic must be admissible for the path to
+// be optimal.
+func FindPathFringe(mp Graph, start, end Node) ([]Node, error) {
+	h, err := mp.HeuristicCost(start, end)
+	if err != nil {
+		return nil, err
+	}
+	root := &fringeNode{node: start, h: h}
+	cache := map[Node]*fringeNode{start: root}
+	var list fringe
+	list.insertAfter(nil, root)
+
+	limit := root.g + root.h
+	var edges []Edge
+	for list.head != nil {
+		fmin := math.Inf(1)
+		for n := list.head; n != nil; {
+			f := n.g + n.h
+			if f > limit {
+				fmin = math.Min(fmin, f)
+				n = n.next
+				continue
+			}
+			if n.node == end {
+				return n.path(), nil
+			}
+			edges, err = mp.Neighbors(n.node, edges[:0])
+			if err != nil {
+				return nil, err
+			}
+			// Children go right after n so they're visited in this pass
+			for i := len(edges) - 1; i >= 0; i-- {
+				edge := edges[i]
+				g := n.g + edge.Cost
+				child := cache[edge.Node]
+				if child == nil {
+					h, err := mp.HeuristicCost(edge.Node, end)
+					if err != nil {
+						return nil, err
+					}
+					child = &fringeNode{node: edge.Node, h: h}
+					cache[edge.Node] = child
+				} else if g >= child.g {
+					continue
+				} else if child.listed {
+					list.remove(child)
+				}
+				child.g = g
+				child.parent = n
+				list.insertAfter(n, child)
+			}
+			next := n.next
+			list.remove(n)
+			n = next
+		}
+		limit = fmin
+	}
+	return nil, ErrImpossible
+}
+
+func (n *fringeNode) path() []Node {
+	var path []Node
+	for ; n != nil; n = n.parent {
+		path = append(path, n.node)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
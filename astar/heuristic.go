@@ -0,0 +1,91 @@
+package astar
+
+import (
+	"math"
+)
+
+// HeuristicViolation describes a place where a heuristic is not
+// consistent: the heuristic cost of Node is more than the cost of moving
+// to Neighbor plus the heuristic cost of Neighbor. A consistent heuristic
+// is also admissible which guarantees optimal paths. For the check that
+// the heuristic cost of the goal is zero, Neighbor is the goal too and
+// EdgeCost and NeighborH are zero.
+type HeuristicViolation struct {
+	Node      Node
+	Neighbor  Node
+	EdgeCost  float64 // cost of the edge from Node to Neighbor
+	H         float64 // heuristic cost from Node to the goal
+	NeighborH float64 // heuristic cost from Neighbor to the goal
+	Excess    float64 // amount by which H is too large
+}
+
+// heuristicTolerance allows for rounding error when comparing costs.
+func heuristicTolerance(v float64) float64 {
+	return 1e-9 * math.Max(1, math.Abs(v))
+}
+
+// CheckHeuristic tests that the heuristic of the graph is consistent for
+// each of the given nodes and its neighbors with respect to the goal, and
+// that the heuristic cost of the goal itself is zero. It returns every
+// violation found. It's meant as a debugging aid when a search returns
+// surprising paths.
+func CheckHeuristic(mp Graph, nodes []Node, goal Node) ([]HeuristicViolation, error) {
+	var violations []HeuristicViolation
+	h, err := mp.HeuristicCost(goal, goal)
+	if err != nil {
+		return nil, err
+	}
+	if math.Abs(h) > heuristicTolerance(0) {
+		violations = append(violations, HeuristicViolation{
+			Node:     goal,
+			Neighbor: goal,
+			H:        h,
+			Excess:   h,
+		})
+	}
+	var edges []Edge
+	for _, n := range nodes {
+		h, err := mp.HeuristicCost(n, goal)
+		if err != nil {
+			return nil, err
+		}
+		edges, err = mp.Neighbors(n, edges[:0])
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range edges {
+			nh, err := mp.HeuristicCost(e.Node, goal)
+			if err != nil {
+				return nil, err
+			}
+			if excess := h - (e.Cost + nh); excess > heuristicTolerance(h) {
+				violations = append(violations, HeuristicViolation{
+					Node:      n,
+					Neighbor:  e.Node,
+					EdgeCost:  e.Cost,
+					H:         h,
+					NeighborH: nh,
+					Excess:    excess,
+				})
+			}
+		}
+	}
+	return violations, nil
+}
+
+// MaxHeuristic returns a heuristic whose cost is the largest of the
+// costs of the given heuristics, or 0 if there are none. If each of them
+// is admissible, never more than the actual cost, then so is their
+// maximum, and since it's at least as close to the actual cost as any
+// one of them the search usually expands fewer nodes. The same goes for
+// consistency. It's a way to combine heuristics that are each accurate
+// in different places, such as the straight line distance and landmarks.
+func MaxHeuristic(hs ...func(a, b Node) float64) func(a, b Node) float64 {
+	return func(a, b Node) float64 {
+		h := 0.0
+		for _, fn := range hs {
+			h = math.Max(h, fn(a, b))
+		}
+		return h
+	}
+}
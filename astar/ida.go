@@ -0,0 +1,92 @@
+package astar
+
+import (
+	"math"
+)
+
+// ida holds the state of an IDA* search.
+type ida struct {
+	mp    Graph
+	end   Node
+	path  []Node
+	edges [][]Edge // reusable neighbor buffers by depth
+}
+
+// FindPathIDA finds the optimal path from start to end using
+// Iterative-Deepening A*. It repeats a depth-first search bounded by an
+// f cost threshold, starting at HeuristicCost(start, end) and raising it
+// to the lowest f cost that exceeded it on every iteration. Only the
+// current path is kept in memory so it can search graphs far too large
+// for FindPath, but nodes are visited again on every iteration and along
+// every path that reaches them, which makes it slow on graphs with many
+// paths between nodes such as grids. Paths that revisit a node are
+// skipped. The heuristic must be admissible for the path to be optimal.
+func FindPathIDA(mp Graph, start, end Node) ([]Node, error) {
+	bound, err := mp.HeuristicCost(start, end)
+	if err != nil {
+		return nil, err
+	}
+	s := &ida{mp: mp, end: end, path: []Node{start}}
+	for {
+		t, found, err := s.search(0, bound)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			return append([]Node(nil), s.path...), nil
+		}
+		if math.IsInf(t, 1) {
+			return nil, ErrImpossible
+		}
+		bound = t
+	}
+}
+
+// search extends the current path depth-first while its f cost is within
+// bound. It reports whether the end was reached and otherwise returns
+// the lowest f cost that exceeded the bound.
+func (s *ida) search(g, bound float64) (float64, bool, error) {
+	node := s.path[len(s.path)-1]
+	h, err := s.mp.HeuristicCost(node, s.end)
+	if err != nil {
+		return 0, false, err
+	}
+	if f := g + h; f > bound {
+		return f, false, nil
+	}
+	if node == s.end {
+		return g, true, nil
+	}
+	depth := len(s.path) - 1
+	if depth == len(s.edges) {
+		s.edges = append(s.edges, nil)
+	}
+	edges, err := s.mp.Neighbors(node, s.edges[depth][:0])
+	if err != nil {
+		return 0, false, err
+	}
+	s.edges[depth] = edges
+	min := math.Inf(1)
+	for _, edge := range edges {
+		if s.onPath(edge.Node) {
+			continue
+		}
+		s.path = append(s.path, edge.Node)
+		t, found, err := s.search(g+edge.Cost, bound)
+		if found || err != nil {
+			return t, found, err
+		}
+		s.path = s.path[:len(s.path)-1]
+		min = math.Min(min, t)
+	}
+	return min, false, nil
+}
+
+func (s *ida) onPath(node Node) bool {
+	for _, n := range s.path {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
@@ -2,10 +2,71 @@ package astar
 
 import (
 	"errors"
+	"fmt"
 )
 
 var ErrImpossible = errors.New("astar: no path exists between start and end")
 
+// NoPathError is returned when a search from a single start to a single
+// end explores every node it can reach without finding end. It wraps
+// ErrImpossible so errors.Is(err, ErrImpossible) reports true.
+type NoPathError struct {
+	Start, End    Node
+	NodesExpanded int // nodes expanded before the search gave up
+}
+
+func (e *NoPathError) Error() string {
+	return fmt.Sprintf("astar: no path exists from %d to %d (%d nodes expanded)", e.Start, e.End, e.NodesExpanded)
+}
+
+func (e *NoPathError) Unwrap() error {
+	return ErrImpossible
+}
+
+// ErrBudgetExceeded is returned when a search gives up after removing
+// more than its limit of nodes from the open list.
+var ErrBudgetExceeded = errors.New("astar: search exceeded its node budget")
+
+// ErrGreedyPath is returned along with the path when a search switched to
+// greedy best-first search before reaching the end, so the path may not
+// be optimal.
+var ErrGreedyPath = errors.New("astar: path found by greedy search may not be optimal")
+
+// ErrBadHeuristic is returned when Options.CheckGoalHeuristic is set and
+// the heuristic cost from an end to itself isn't zero.
+var ErrBadHeuristic = errors.New("astar: heuristic cost from the end to itself isn't zero")
+
+// ErrPathTooLong is returned when every path found to the end has more
+// edges than the maximum path length of the search.
+var ErrPathTooLong = errors.New("astar: path exceeds the maximum length")
+
+// ErrInvalidCost is wrapped by the error returned when a graph returns
+// an edge with a negative or NaN cost, which A* can't handle.
+var ErrInvalidCost = errors.New("astar: invalid edge cost")
+
+// InvalidCostError identifies an edge with an invalid cost.
+type InvalidCostError struct {
+	From, To Node
+	Cost     float64
+}
+
+func (e *InvalidCostError) Error() string {
+	return fmt.Sprintf("astar: invalid cost %g for edge from %d to %d", e.Cost, e.From, e.To)
+}
+
+func (e *InvalidCostError) Unwrap() error {
+	return ErrInvalidCost
+}
+
+// ErrNegativeCycle is returned by FindPathGeneral when a cycle of edges
+// whose costs sum to less than zero can be reached from the start, so
+// there's no cheapest path.
+var ErrNegativeCycle = errors.New("astar: graph has a negative cycle")
+
+// ErrCancelled is returned when a search is stopped through its stop
+// channel.
+var ErrCancelled = errors.New("astar: search cancelled")
+
 type Node int64
 
 type Edge struct {
@@ -16,7 +77,20 @@ type Edge struct {
 type Graph interface {
 	// Edges is passed in for reuse. This method gets called a large number of times
 	// so we don't want to allocate an Edge slice for every call.
+	//
+	// Neighbors should append the edges out of node to edges and return
+	// the result. The search keeps ownership of edges and passes the same
+	// backing array to every call, so the graph must not hold on to edges
+	// or to the slice it returns when that was appended to edges, as the
+	// next call overwrites them. A graph that needs to keep them must
+	// copy them or the search must be run with Options.CopyEdges. The
+	// search only reads the returned slice and never passes it back to
+	// the graph, so a graph may instead return a slice it owns, such as a
+	// cached list of edges, which the search doesn't retain past the next
+	// call.
 	Neighbors(node Node, edges []Edge) ([]Edge, error)
+	// HeuristicCost may return +Inf if end can't be reached from start.
+	// Such nodes are never added to the open list.
 	HeuristicCost(start, end Node) (float64, error)
 }
 
@@ -27,6 +101,187 @@ type PossiblePath interface {
 	PossiblePath(path []Node, cost float64)
 }
 
+// If a graph implements the DenseGraph interface then its nodes are
+// assumed to be numbered densely from 0 to NodeCount()-1 and the search
+// keeps track of them in a slice rather than a map, which is much faster.
+// Nodes outside of that range are still handled correctly.
+type DenseGraph interface {
+	NodeCount() int
+}
+
+// If a graph implements the Sizer interface then ExpectedNodes is used
+// to size the data structures of a search up front instead of growing
+// them as nodes are found. It should return roughly the number of nodes
+// a typical search visits.
+type Sizer interface {
+	ExpectedNodes() int
+}
+
+// ReverseGraph is a graph that can also enumerate the edges leading
+// into a node, which is needed by algorithms that search backwards from
+// the goal.
+type ReverseGraph interface {
+	Graph
+	// ReverseNeighbors appends the edges into node. The Node field of each
+	// returned edge is the source of the edge rather than its destination.
+	ReverseNeighbors(node Node, edges []Edge) ([]Edge, error)
+}
+
+// If a graph implements the Undirected interface then every edge can be
+// followed in both directions at the same cost, so Neighbors also gives
+// the edges into a node and the graph can be used where a ReverseGraph
+// is needed without implementing ReverseNeighbors. Declaring a directed
+// graph Undirected gives wrong results. The Undirected method itself is
+// never called.
+type Undirected interface {
+	Undirected()
+}
+
+// ErrNotReversible is returned by functions that follow edges backwards
+// when given a graph that is neither a ReverseGraph nor Undirected.
+var ErrNotReversible = errors.New("astar: graph can't enumerate the edges into a node")
+
+// ErrInvalidSeed is returned by FindPathSeeded when a node is seeded more
+// than once or following the parents of a seed doesn't lead to a seed
+// with a parent of -1.
+var ErrInvalidSeed = errors.New("astar: seeded nodes don't form paths")
+
+// ErrNotDense is returned by functions that need to visit every node of
+// a graph when given a graph that isn't a DenseGraph.
+var ErrNotDense = errors.New("astar: graph doesn't number its nodes densely")
+
+// LineOfSightGraph is a graph with a notion of straight lines between
+// nodes as used by any-angle path finding.
+type LineOfSightGraph interface {
+	Graph
+	// LineOfSight returns true if a straight line between a and b is
+	// unobstructed. The cost of moving along the line must be
+	// HeuristicCost(a, b).
+	LineOfSight(a, b Node) bool
+}
+
+// If a graph implements the TurnCost interface then the cost of moving
+// from via to to after arriving at via from from is added to the cost of
+// the edge from via to to. This allows penalizing sharp turns. Since the
+// cost of a path then depends on the direction a node is entered from,
+// the search tracks each node separately for every neighbor it's entered
+// from, which uses up to the number of neighbors times as much memory.
+// Turn costs must not be negative.
+type TurnCost interface {
+	TurnCost(from, via, to Node) float64
+}
+
+// If a graph implements the DirectionalGraph interface then the search
+// calls NeighborsFrom instead of Neighbors, passing the node the current
+// node was entered from or -1 for a start node. This allows the edges
+// out of a node to depend on the direction of arrival, for instance to
+// forbid turning around. Like with TurnCost the search then tracks each
+// node separately for every neighbor it's entered from, so a path may
+// pass through the same node more than once.
+type DirectionalGraph interface {
+	NeighborsFrom(node, parent Node, edges []Edge) ([]Edge, error)
+}
+
+// If a graph implements the Validator interface then every edge returned
+// by Neighbors is checked with Valid and edges to invalid nodes are
+// skipped instead of being searched, which keeps a buggy Neighbors from
+// passing nodes that don't exist back into the graph. The number of
+// edges skipped is reported in Stats.InvalidEdges.
+type Validator interface {
+	Valid(node Node) bool
+}
+
+// If a graph implements the NodeCost interface then the cost of entering
+// a node is added to the cost of every edge into it, which is simpler
+// than including it in the cost of each edge for terrain where the cost
+// depends on the destination. For the heuristic to remain admissible it
+// must include the lowest cost of entering the nodes along the way,
+// which without more knowledge of the graph means the cost of the goal
+// at most. Node costs must not be negative.
+type NodeCost interface {
+	NodeCost(node Node) float64
+}
+
+// If a graph implements the TimeDependentGraph interface then the cost
+// of each edge returned by Neighbors is replaced by EdgeCostAt, given the
+// cost of the path to from as the time of arrival there. This allows
+// modelling costs that change over time such as traffic. The path found
+// is optimal only if leaving later never means arriving sooner (waiting
+// doesn't help) and the heuristic remains a lower bound on the cost to
+// the goal at any time.
+type TimeDependentGraph interface {
+	EdgeCostAt(from, to Node, arrivalCost float64) float64
+}
+
+// If a graph implements the DuplicateEdges interface then when Neighbors
+// returns more than one edge to the same node only the cheapest is used.
+// Duplicate edges never change the path found but without this each one
+// may update the node in the open list. Edges from a node to itself are
+// always ignored. The DuplicateEdges method itself is never called.
+type DuplicateEdges interface {
+	DuplicateEdges()
+}
+
+// If a graph implements the CacheableHeuristic interface then the
+// heuristic cost from a node to the goal is assumed not to change during
+// a search and is computed at most once per node. The heuristic cost is
+// always stored with each node a search tracks, so this only saves work
+// when a node is tracked more than once, such as when a graph implements
+// TurnCost. The CacheableHeuristic method itself is never called.
+type CacheableHeuristic interface {
+	CacheableHeuristic()
+}
+
+// If a graph implements the ConsistentHeuristic interface then its
+// heuristic is assumed to be consistent (monotone): the heuristic cost of
+// a node is never more than the cost of an edge out of it plus the
+// heuristic cost of the node it leads to. The first path found to a node
+// is then always the cheapest, so once a node has been expanded the
+// search skips every later edge into it without working out its cost.
+// If the heuristic isn't consistent the path found may not be optimal,
+// and the same goes for weighted searches, which can find cheaper paths
+// to nodes already expanded. It's ignored by any-angle searches.
+// CheckHeuristic can verify the claim. The ConsistentHeuristic method
+// itself is never called.
+type ConsistentHeuristic interface {
+	ConsistentHeuristic()
+}
+
+// If a graph implements the ExpensiveHeuristic interface then the
+// heuristic cost of a node is only worked out when the node is about to
+// be expanded instead of as soon as it's found (lazy A*), which saves
+// calling HeuristicCost for nodes found but never expanded. Until then
+// the node is ordered by an estimate, the larger of
+// HeuristicLowerBound and the heuristic cost of its parent less the cost
+// of the step from it, and if the real heuristic cost turns out higher
+// the node goes back in the open list. Both must be lower bounds for the
+// path to be optimal, so the heuristic must be consistent and
+// HeuristicLowerBound must never exceed HeuristicCost. The more of the
+// heuristic HeuristicLowerBound captures, the fewer calls are saved,
+// while a lower bound of 0 saves few.
+type ExpensiveHeuristic interface {
+	// HeuristicLowerBound is a cheap lower bound on HeuristicCost.
+	HeuristicLowerBound(start, end Node) float64
+}
+
+// If a graph implements the Progress interface then Progress is called
+// periodically during a search with a rough estimate of the fraction of
+// the search that's complete. The estimate is based on how much closer
+// to the goal, according to the heuristic, the node being expanded is
+// than the start. It's meant for display, isn't necessarily monotonic,
+// and doesn't affect the result.
+type Progress interface {
+	Progress(fraction float64)
+}
+
 type Debug interface {
 	VisitedNode(node, parentNode Node, currentCost, predictedCost float64)
 }
+
+// If a graph implements the GenerationDebug interface then GeneratedNode
+// is called when a node is first added to the open list, as opposed to
+// Debug.VisitedNode which is called when it's expanded. Start nodes have
+// a parent of -1.
+type GenerationDebug interface {
+	GeneratedNode(node, parentNode Node, currentCost, predictedCost float64)
+}
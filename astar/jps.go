@@ -0,0 +1,232 @@
+package astar
+
+import (
+	"math"
+)
+
+// UniformGrid is an 8-connected grid where every walkable cell can be
+// entered from any of its neighbors. Straight moves cost 1 and diagonal
+// moves cost sqrt(2). Diagonal moves are allowed whenever the destination
+// cell is walkable. The node for cell (x, y) is y*width + x.
+type UniformGrid interface {
+	Dimensions() (width, height int)
+	Walkable(x, y int) bool
+}
+
+type jps struct {
+	grid          UniformGrid
+	width, height int
+	endX, endY    int
+}
+
+func (j *jps) walkable(x, y int) bool {
+	return x >= 0 && y >= 0 && x < j.width && y < j.height && j.grid.Walkable(x, y)
+}
+
+// jump moves from (x, y) in direction (dx, dy) until it finds a jump
+// point: the goal or a cell with a forced neighbor.
+func (j *jps) jump(x, y, dx, dy int) (int, int, bool) {
+	for {
+		x += dx
+		y += dy
+		if !j.walkable(x, y) {
+			return 0, 0, false
+		}
+		if x == j.endX && y == j.endY {
+			return x, y, true
+		}
+		switch {
+		case dx != 0 && dy != 0:
+			if (j.walkable(x-dx, y+dy) && !j.walkable(x-dx, y)) ||
+				(j.walkable(x+dx, y-dy) && !j.walkable(x, y-dy)) {
+				return x, y, true
+			}
+			// A diagonal move is a jump point if a straight jump from it
+			// reaches one.
+			if _, _, ok := j.jump(x, y, dx, 0); ok {
+				return x, y, true
+			}
+			if _, _, ok := j.jump(x, y, 0, dy); ok {
+				return x, y, true
+			}
+		case dx != 0:
+			if (j.walkable(x+dx, y+1) && !j.walkable(x, y+1)) ||
+				(j.walkable(x+dx, y-1) && !j.walkable(x, y-1)) {
+				return x, y, true
+			}
+		default:
+			if (j.walkable(x+1, y+dy) && !j.walkable(x+1, y)) ||
+				(j.walkable(x-1, y+dy) && !j.walkable(x-1, y)) {
+				return x, y, true
+			}
+		}
+	}
+}
+
+// directions returns the pruned set of directions to search from (x, y)
+// when it was reached from (px, py).
+func (j *jps) directions(x, y, px, py int, dirs [][2]int) [][2]int {
+	dx := sign(x - px)
+	dy := sign(y - py)
+	switch {
+	case dx != 0 && dy != 0:
+		dirs = append(dirs, [2]int{0, dy}, [2]int{dx, 0}, [2]int{dx, dy})
+		if !j.walkable(x-dx, y) {
+			dirs = append(dirs, [2]int{-dx, dy})
+		}
+		if !j.walkable(x, y-dy) {
+			dirs = append(dirs, [2]int{dx, -dy})
+		}
+	case dx != 0:
+		dirs = append(dirs, [2]int{dx, 0})
+		if !j.walkable(x, y+1) {
+			dirs = append(dirs, [2]int{dx, 1})
+		}
+		if !j.walkable(x, y-1) {
+			dirs = append(dirs, [2]int{dx, -1})
+		}
+	default:
+		dirs = append(dirs, [2]int{0, dy})
+		if !j.walkable(x+1, y) {
+			dirs = append(dirs, [2]int{1, dy})
+		}
+		if !j.walkable(x-1, y) {
+			dirs = append(dirs, [2]int{-1, dy})
+		}
+	}
+	return dirs
+}
+
+func sign(v int) int {
+	switch {
+	case v < 0:
+		return -1
+	case v > 0:
+		return 1
+	}
+	return 0
+}
+
+// octile returns the cost of the cheapest 8-connected move sequence
+// between two cells on an open grid.
+func octile(dx, dy int) float64 {
+	dx, dy = abs(dx), abs(dy)
+	if dx < dy {
+		dx, dy = dy, dx
+	}
+	return float64(dx-dy) + math.Sqrt2*float64(dy)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// FindPathJPS finds the optimal path from start to end on a uniform-cost
+// grid using Jump Point Search, which prunes the symmetric paths plain A*
+// would explore and is usually an order of magnitude faster. The path
+// cost is identical to FindPath on the equivalent Graph. The returned
+// path contains every cell along the way, not only the jump points.
+func FindPathJPS(mp UniformGrid, start, end Node) ([]Node, error) {
+	width, height := mp.Dimensions()
+	if width <= 0 || height <= 0 {
+		return nil, ErrImpossible
+	}
+	w := Node(width)
+	j := &jps{
+		grid:   mp,
+		width:  width,
+		height: height,
+		endX:   int(end % w),
+		endY:   int(end / w),
+	}
+	sx, sy := int(start%w), int(start/w)
+	if !j.walkable(sx, sy) || !j.walkable(j.endX, j.endY) {
+		return nil, ErrImpossible
+	}
+
+	state := newState(0)
+	state.setDense(width * height)
+	ni := state.newNodeInfo()
+	*ni = OpenNode{
+		node:          start,
+		parent:        nil,
+		predictedCost: octile(j.endX-sx, j.endY-sy),
+	}
+	state.addNodeInfo(ni)
+
+	dirs := make([][2]int, 0, 8)
+	for {
+		current := state.popBest()
+		if current == nil {
+			return nil, ErrImpossible
+		}
+		if current.node == end {
+			path, err := state.pathToNode(current)
+			if err != nil {
+				return nil, err
+			}
+			return expandJumpPath(path, w), nil
+		}
+		x, y := int(current.node%w), int(current.node/w)
+		dirs = dirs[:0]
+		if current.parent == nil {
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					if dx != 0 || dy != 0 {
+						dirs = append(dirs, [2]int{dx, dy})
+					}
+				}
+			}
+		} else {
+			p := current.parent.node
+			dirs = j.directions(x, y, int(p%w), int(p/w), dirs)
+		}
+		for _, d := range dirs {
+			jx, jy, ok := j.jump(x, y, d[0], d[1])
+			if !ok {
+				continue
+			}
+			node := Node(jy)*w + Node(jx)
+			cost := current.cost + octile(jx-x, jy-y)
+			ni := state.get(node)
+			if ni == nil {
+				ni = state.newNodeInfo()
+				*ni = OpenNode{
+					node:          node,
+					parent:        current,
+					cost:          cost,
+					predictedCost: octile(j.endX-jx, j.endY-jy),
+				}
+				state.addNodeInfo(ni)
+			} else if cost < ni.cost {
+				ni.parent = current
+				ni.cost = cost
+				state.updateNodeInfo(ni)
+			}
+		}
+	}
+}
+
+// expandJumpPath fills in the cells between consecutive jump points
+// which are always connected by a straight or diagonal line.
+func expandJumpPath(jumps []Node, w Node) []Node {
+	if len(jumps) == 0 {
+		return jumps
+	}
+	path := make([]Node, 0, len(jumps)*4)
+	path = append(path, jumps[0])
+	for i := 1; i < len(jumps); i++ {
+		x, y := int(jumps[i-1]%w), int(jumps[i-1]/w)
+		ex, ey := int(jumps[i]%w), int(jumps[i]/w)
+		dx, dy := sign(ex-x), sign(ey-y)
+		for x != ex || y != ey {
+			x += dx
+			y += dy
+			path = append(path, Node(y)*w+Node(x))
+		}
+	}
+	return path
+}
@@ -0,0 +1,85 @@
+package astar
+
+import (
+	"math"
+)
+
+// Landmarks is a heuristic for repeated searches on a static graph based
+// on precomputed distances to and from a few landmark nodes (the ALT
+// technique). By the triangle inequality the difference between the
+// distances of two nodes to a landmark is a lower bound on the distance
+// between them. Landmarks on the periphery of the graph, behind the
+// nodes from the point of view of typical queries, give the tightest
+// bounds.
+//
+// The distances from and to every landmark are kept for every node that
+// can reach or be reached from it, so the memory used grows with twice
+// the number of landmarks times the number of nodes.
+type Landmarks struct {
+	from []map[Node]float64 // distance from each landmark to a node
+	to   []map[Node]float64 // distance from a node to each landmark
+}
+
+// Precompute finds the distances from and to each of the landmarks
+// using Dijkstra's algorithm, replacing any previous distances. Like
+// CostField it needs a ReverseGraph or Undirected graph.
+func (l *Landmarks) Precompute(mp Graph, landmarks []Node) error {
+	rg, err := reverse(mp)
+	if err != nil {
+		return err
+	}
+	_, undirected := mp.(Undirected)
+	l.from = make([]map[Node]float64, len(landmarks))
+	l.to = make([]map[Node]float64, len(landmarks))
+	for i, lm := range landmarks {
+		from := make(map[Node]float64)
+		_, _, err := findPathGoals(zeroHeuristic{mp}, []Node{lm}, nil, config{explored: from})
+		if err != nil && err != ErrImpossible {
+			return err
+		}
+		// On an undirected graph the distances either way are the same
+		to := from
+		if !undirected {
+			if to, err = CostField(rg, lm); err != nil {
+				return err
+			}
+		}
+		l.from[i] = from
+		l.to[i] = to
+	}
+	return nil
+}
+
+// Heuristic returns a lower bound on the cost of a path from one node to
+// another. It returns +Inf if the distances show that there's no such
+// path, which prunes the node when used as the HeuristicCost of a graph:
+//
+//	g := NewGraphFunc(mp.Neighbors, func(a, b Node) (float64, error) {
+//		return lm.Heuristic(a, b), nil
+//	})
+func (l *Landmarks) Heuristic(from, to Node) float64 {
+	h := 0.0
+	for i := range l.from {
+		// d(L, to) <= d(L, from) + d(from, to)
+		df, okf := l.from[i][from]
+		dt, okt := l.from[i][to]
+		if okf && !okt {
+			// If to was reachable from from it'd be reachable from L
+			return math.Inf(1)
+		}
+		if okf && okt {
+			h = math.Max(h, dt-df)
+		}
+		// d(from, L) <= d(from, to) + d(to, L)
+		df, okf = l.to[i][from]
+		dt, okt = l.to[i][to]
+		if okt && !okf {
+			// If from could reach to it could also reach L
+			return math.Inf(1)
+		}
+		if okf && okt {
+			h = math.Max(h, df-dt)
+		}
+	}
+	return h
+}
@@ -0,0 +1,126 @@
+package astar
+
+// heapData is a binary heap whose elements track their own index.
+type heapData interface {
+	less(i, j int) bool
+	swap(i, j int)
+}
+
+func heapUp(h heapData, j int) {
+	for {
+		i := (j - 1) / 2 // parent
+		if i == j || !h.less(j, i) {
+			break
+		}
+		h.swap(i, j)
+		j = i
+	}
+}
+
+// heapDown moves element i down the heap of size n and reports
+// whether it moved.
+func heapDown(h heapData, i0, n int) bool {
+	i := i0
+	for {
+		j1 := 2*i + 1
+		if j1 >= n || j1 < 0 { // j1 < 0 after int overflow
+			break
+		}
+		j := j1 // left child
+		if j2 := j1 + 1; j2 < n && !h.less(j1, j2) {
+			j = j2 // = 2*i + 2  // right child
+		}
+		if !h.less(j, i) {
+			break
+		}
+		h.swap(i, j)
+		i = j
+	}
+	return i > i0
+}
+
+// heapFix restores the heap ordering after the priority of element i
+// has changed.
+func heapFix(h heapData, i, n int) {
+	if !heapDown(h, i, n) {
+		heapUp(h, i)
+	}
+}
+
+// An Item is an element of a PriorityQueue.
+type Item interface {
+	// Priority returns the priority of the item. Items with a lower
+	// priority are popped first.
+	Priority() float64
+}
+
+// PriorityQueue is a binary min-heap of items which supports changing
+// the priority of an item while it's in the queue (decrease-key). Items
+// are identified by equality so they're usually pointers. The zero value
+// is an empty queue ready to use.
+type PriorityQueue struct {
+	items []Item
+	index map[Item]int
+}
+
+func (pq *PriorityQueue) less(i, j int) bool {
+	return pq.items[i].Priority() < pq.items[j].Priority()
+}
+
+func (pq *PriorityQueue) swap(i, j int) {
+	it := pq.items
+	it[i], it[j] = it[j], it[i]
+	pq.index[it[i]] = i
+	pq.index[it[j]] = j
+}
+
+// Len returns the number of items in the queue.
+func (pq *PriorityQueue) Len() int {
+	return len(pq.items)
+}
+
+// Contains reports whether the item is in the queue.
+func (pq *PriorityQueue) Contains(it Item) bool {
+	_, ok := pq.index[it]
+	return ok
+}
+
+// Push adds an item to the queue. If the item is already in the queue
+// then its position is updated instead.
+func (pq *PriorityQueue) Push(it Item) {
+	if pq.index == nil {
+		pq.index = make(map[Item]int)
+	}
+	if i, ok := pq.index[it]; ok {
+		heapFix(pq, i, len(pq.items))
+		return
+	}
+	pq.items = append(pq.items, it)
+	i := len(pq.items) - 1
+	pq.index[it] = i
+	heapUp(pq, i)
+}
+
+// Pop removes and returns the item with the lowest priority, or nil if
+// the queue is empty.
+func (pq *PriorityQueue) Pop() Item {
+	n := len(pq.items) - 1
+	if n < 0 {
+		return nil
+	}
+	pq.swap(0, n)
+	heapDown(pq, 0, n)
+	it := pq.items[n]
+	pq.items[n] = nil
+	pq.items = pq.items[:n]
+	delete(pq.index, it)
+	return it
+}
+
+// Update restores the ordering of the queue after the priority of the
+// item has changed. It does nothing if the item isn't in the queue.
+func (pq *PriorityQueue) Update(it Item) {
+	if i, ok := pq.index[it]; ok {
+		heapFix(pq, i, len(pq.items))
+	}
+}
@@ -0,0 +1,125 @@
+package astar
+
+import (
+	"context"
+	"sort"
+)
+
+// SearchState is a search interrupted by FindPathResumable that can be
+// continued with Resume. It keeps the open list and every node record
+// of the search alive between calls.
+type SearchState struct {
+	mp         Graph
+	start, end Node
+	cfg        config
+
+	// The result once the search is over
+	done  bool
+	path  []Node
+	err   error
+	stats Stats
+}
+
+// FindPathResumable is like FindPathLimited but when it gives up after
+// removing maxNodes nodes from the open list it returns ErrBudgetExceeded
+// along with the state of the search, which Resume continues from where
+// it stopped. This lets a long search be spread across frames or
+// requests. The state is returned whatever the outcome and once the
+// search is over Resume keeps returning its result.
+func FindPathResumable(mp Graph, start, end Node, maxNodes int) ([]Node, *SearchState, error) {
+	return FindPathResumableContext(context.Background(), mp, start, end, maxNodes)
+}
+
+// FindPathResumableContext is like FindPathResumable but also stops the
+// search and returns ctx.Err() if the context is cancelled, in which
+// case the search can be resumed as if it had run out of nodes.
+func FindPathResumableContext(ctx context.Context, mp Graph, start, end Node, maxNodes int) ([]Node, *SearchState, error) {
+	st := &SearchState{
+		mp:    mp,
+		start: start,
+		end:   end,
+		cfg:   config{state: newState(defaultCapacity(start, end))},
+	}
+	if start == end {
+		st.finish([]Node{start}, nil)
+		return st.path, st, nil
+	}
+	path, err := ResumeContext(ctx, st, maxNodes)
+	return path, st, err
+}
+
+// Resume continues a search returned by FindPathResumable, removing up
+// to additionalNodes more nodes from the open list. It returns
+// ErrBudgetExceeded if the search still hasn't finished, in which case it
+// may be resumed again.
+func Resume(st *SearchState, additionalNodes int) ([]Node, error) {
+	return ResumeContext(context.Background(), st, additionalNodes)
+}
+
+// ResumeContext is like Resume but also stops the search and returns
+// ctx.Err() if the context is cancelled, in which case it may be resumed
+// again. The context is only checked every thousand or so nodes.
+func ResumeContext(ctx context.Context, st *SearchState, additionalNodes int) ([]Node, error) {
+	if st.done {
+		return st.path, st.err
+	}
+	if additionalNodes <= 0 {
+		return nil, ErrBudgetExceeded
+	}
+	cfg := st.cfg
+	cfg.ctx = ctx
+	cfg.maxNodes = additionalNodes
+	path, _, err := findPathGoals(st.mp, []Node{st.start}, goals{st.end}, cfg)
+	// The context is checked before a node is removed from the open
+	// list so the state is as complete as when the budget runs out
+	if err != ErrBudgetExceeded && (err == nil || err != ctx.Err()) {
+		st.finish(path, err)
+	}
+	return path, err
+}
+
+func (st *SearchState) finish(path []Node, err error) {
+	st.done = true
+	st.path = path
+	st.err = err
+	st.stats = st.cfg.state.stats
+	// Nothing is left to resume so let the memory go
+	st.cfg.state = nil
+}
+
+// Done reports whether the search is over, successfully or not.
+func (st *SearchState) Done() bool {
+	return st.done
+}
+
+// Stats returns statistics about the search so far.
+func (st *SearchState) Stats() Stats {
+	if st.cfg.state == nil {
+		return st.stats
+	}
+	return st.cfg.state.stats
+}
+
+// Frontier returns the path from the start to every node on the open
+// list of an interrupted search, in the order the search would expand
+// them, so the most promising path comes first. It returns nil once the
+// search is over.
+func (st *SearchState) Frontier() ([][]Node, error) {
+	s := st.cfg.state
+	if s == nil {
+		return nil, nil
+	}
+	open := append([]*OpenNode(nil), s.heap...)
+	sort.Slice(open, func(i, j int) bool {
+		return s.lessNodes(open[i], open[j])
+	})
+	paths := make([][]Node, len(open))
+	for i, ni := range open {
+		path, err := s.pathToNode(ni)
+		if err != nil {
+			return nil, err
+		}
+		paths[i] = path
+	}
+	return paths, nil
+}
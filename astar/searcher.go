@@ -0,0 +1,104 @@
+package astar
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// A Searcher finds paths while reusing the memory allocated by previous
+// searches, which avoids most allocations when answering many queries.
+// The zero value is ready to use. A Searcher is not safe for concurrent
+// use by multiple goroutines.
+type Searcher struct {
+	// WarnInadmissible makes FindPath compare the heuristic cost of every
+	// node on the path it finds with the actual cost of the rest of the
+	// path, and report each overestimate through Warnings. An
+	// overestimating heuristic can make A* miss the optimal path. This is
+	// only a spot check of the nodes on the path, so a heuristic that
+	// passes isn't necessarily admissible. The CheckHeuristic function
+	// is the thorough but much slower test.
+	WarnInadmissible bool
+
+	state    *state
+	warnings []string
+}
+
+func (s *Searcher) reset() *state {
+	if s.state == nil {
+		s.state = newState(defaultListCapacity)
+	} else {
+		s.state.reset()
+	}
+	return s.state
+}
+
+// FindPath is like the package level FindPath but reuses the memory
+// of the Searcher.
+func (s *Searcher) FindPath(mp Graph, start, end Node) ([]Node, error) {
+	s.warnings = nil
+	cfg := config{state: s.reset()}
+	if !s.WarnInadmissible || start == end {
+		path, _, err := findPath(mp, start, end, cfg)
+		return path, err
+	}
+	state, goal, err := search(mp, []Node{start}, goals{end}, cfg)
+	if err != nil {
+		return nil, err
+	}
+	path, err := state.pathToNode(goal)
+	if err != nil {
+		return nil, err
+	}
+	// Allow for rounding in the sums of costs
+	tolerance := 1e-9 * math.Max(1, goal.cost)
+	for n := goal; n != nil; n = n.parent {
+		if remaining := goal.cost - n.cost; n.predictedCost > remaining+tolerance {
+			s.warnings = append(s.warnings, fmt.Sprintf("astar: heuristic cost %g from %d to %d is more than the cost %g of the path found", n.predictedCost, n.node, end, remaining))
+		}
+	}
+	return path, nil
+}
+
+// Warnings returns the problems found with the heuristic by the last call
+// to FindPath when WarnInadmissible is set, starting from the end of the
+// path, or nil if there were none.
+func (s *Searcher) Warnings() []string {
+	return s.warnings
+}
+
+// FindPathBatch finds the paths for many independent queries, each a
+// start and end node, using up to workers goroutines that each reuse
+// their own Searcher. The paths and errors are returned in the order of
+// the queries. Since the graph is shared by all workers its Neighbors
+// and HeuristicCost methods, and any optional interfaces it implements,
+// must be safe to call concurrently. A workers value below 1 is treated
+// as 1.
+func FindPathBatch(mp Graph, queries [][2]Node, workers int) ([][]Node, []error) {
+	paths := make([][]Node, len(queries))
+	errs := make([]error, len(queries))
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(queries) {
+		workers = len(queries)
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			var s Searcher
+			for i := range next {
+				paths[i], errs[i] = s.FindPath(mp, queries[i][0], queries[i][1])
+			}
+		}()
+	}
+	for i := range queries {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return paths, errs
+}
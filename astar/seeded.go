@@ -0,0 +1,85 @@
+package astar
+
+// SeededNode is a node known to be reachable at Cost by way of Parent,
+// another seeded node, or -1 if it's where the path begins.
+type SeededNode struct {
+	Node   Node
+	Cost   float64
+	Parent Node
+}
+
+// FindPathSeeded finds the optimal path to end from the nodes already
+// reached by the seeds, such as a partial plan from a previous search
+// when replanning. The search begins as if it had already reached every
+// seed at its cost by way of its parents, with every seed in the open
+// list so that routes leaving the plan at any of its nodes are still
+// explored. The returned path begins at a seed with a parent of -1, and
+// it's only optimal if the cost of every seed is that of the cheapest
+// path to it.
+func FindPathSeeded(mp Graph, seeds []SeededNode, end Node) ([]Node, error) {
+	path, _, err := findPathSeeded(mp, seeds, end, config{})
+	return path, err
+}
+
+func findPathSeeded(mp Graph, seeds []SeededNode, end Node, cfg config) ([]Node, float64, error) {
+	var roots []Node
+	for _, sd := range seeds {
+		if sd.Parent == -1 {
+			roots = append(roots, sd.Node)
+		}
+	}
+	if len(roots) == 0 {
+		if len(seeds) == 0 {
+			return nil, 0, ErrImpossible
+		}
+		return nil, 0, ErrInvalidSeed
+	}
+	cfg.seeds = seeds
+	return findPathGoals(mp, roots, goals{end}, cfg)
+}
+
+// addSeeds adds a record for every seed, linked to the record of its
+// parent, and puts them all in the open list.
+func (s *state) addSeeds(mp Graph, ends goals, seeds []SeededNode) error {
+	records := make(map[Node]*OpenNode, len(seeds))
+	for _, sd := range seeds {
+		if !(sd.Cost >= 0) {
+			return &InvalidCostError{From: sd.Parent, To: sd.Node, Cost: sd.Cost}
+		}
+		if records[sd.Node] != nil {
+			return ErrInvalidSeed
+		}
+		h, err := s.heuristicCost(mp, ends, sd.Node)
+		if err != nil {
+			return err
+		}
+		ni := s.newNodeInfo()
+		*ni = OpenNode{node: sd.Node, cost: sd.Cost, predictedCost: h}
+		records[sd.Node] = ni
+	}
+	for _, sd := range seeds {
+		if sd.Parent == -1 {
+			continue
+		}
+		parent := records[sd.Parent]
+		if parent == nil {
+			return ErrInvalidSeed
+		}
+		records[sd.Node].parent = parent
+	}
+	for _, sd := range seeds {
+		ni := records[sd.Node]
+		for n := ni.parent; n != nil; n = n.parent {
+			// The parents go around in a cycle
+			if ni.length == len(seeds) {
+				return ErrInvalidSeed
+			}
+			ni.length++
+		}
+	}
+	for _, sd := range seeds {
+		s.addNodeInfo(records[sd.Node])
+		s.stats.NodesGenerated++
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package astar
+
+import (
+	"iter"
+)
+
+// FindPathSeq is like FindPath but returns the path as a sequence of
+// nodes from start to end instead of a slice. Since the search records
+// the path backwards as parent links from end, the links are reversed in
+// place once the search completes and the sequence walks them forward,
+// so no path slice is ever allocated. The sequence may be iterated any
+// number of times. It keeps the node records of the search alive until
+// it's no longer referenced.
+func FindPathSeq(mp Graph, start, end Node) (iter.Seq[Node], error) {
+	s, ni, err := search(mp, []Node{start}, goals{end}, config{})
+	if err != nil {
+		return nil, err
+	}
+	first, err := s.reverseParents(ni)
+	if err != nil {
+		return nil, err
+	}
+	return func(yield func(Node) bool) {
+		for n := first; n != nil; n = n.parent {
+			if !yield(n.node) {
+				return
+			}
+		}
+	}, nil
+}
+
+// reverseParents reverses the parent links from node back to the start
+// so they lead from the start to node, and returns the start. The links
+// are left alone if they go around in a cycle.
+func (s *state) reverseParents(node *OpenNode) (*OpenNode, error) {
+	n := 0
+	for ni := node; ni != nil; ni = ni.parent {
+		// A path can't visit more nodes than the search has seen
+		if n == s.records {
+			return nil, errParentCycle
+		}
+		n++
+	}
+	var first *OpenNode
+	for ni := node; ni != nil; {
+		next := ni.parent
+		ni.parent = first
+		first = ni
+		ni = next
+	}
+	return first, nil
+}
@@ -0,0 +1,373 @@
+package astar
+
+import (
+	"math"
+)
+
+const (
+	smaOpen = iota
+	smaLeaves
+)
+
+type smaNode struct {
+	node   Node
+	parent *smaNode
+	slot   int // index of the edge from the parent to this node
+	depth  int
+	g, f   float64
+
+	// Successors are generated one at a time. A pruned successor is
+	// forgotten but the f cost it had is remembered in kidF so the best
+	// forgotten successor can be regenerated later.
+	edges    []Edge
+	expanded bool
+	next     int // next edge to generate for the first time
+	kids     []*smaNode
+	kidF     []float64
+	children int // successors in memory
+
+	index [2]int // index in the open list and leaf heap or -1
+}
+
+// smaHeap is a heap of nodes. The open list is ordered by lowest f cost
+// and deepest first and the leaf heap by highest f cost and shallowest
+// first, which is the order in which leaves are pruned.
+type smaHeap struct {
+	nodes []*smaNode
+	which int
+}
+
+func (h *smaHeap) less(i, j int) bool {
+	a, b := h.nodes[i], h.nodes[j]
+	if h.which == smaLeaves {
+		a, b = b, a
+	}
+	if a.f != b.f {
+		return a.f < b.f
+	}
+	if a.depth != b.depth {
+		return a.depth > b.depth
+	}
+	return a.node < b.node
+}
+
+func (h *smaHeap) swap(i, j int) {
+	n := h.nodes
+	n[i], n[j] = n[j], n[i]
+	n[i].index[h.which] = i
+	n[j].index[h.which] = j
+}
+
+func (h *smaHeap) contains(n *smaNode) bool {
+	return n.index[h.which] >= 0
+}
+
+func (h *smaHeap) push(n *smaNode) {
+	n.index[h.which] = len(h.nodes)
+	h.nodes = append(h.nodes, n)
+	heapUp(h, n.index[h.which])
+}
+
+func (h *smaHeap) remove(n *smaNode) {
+	i := n.index[h.which]
+	last := len(h.nodes) - 1
+	if i != last {
+		h.swap(i, last)
+	}
+	h.nodes[last] = nil
+	h.nodes = h.nodes[:last]
+	if i != last {
+		heapFix(h, i, last)
+	}
+	n.index[h.which] = -1
+}
+
+func (h *smaHeap) fix(n *smaNode) {
+	if i := n.index[h.which]; i >= 0 {
+		heapFix(h, i, len(h.nodes))
+	}
+}
+
+// sma holds the state of a memory-bounded search.
+type sma struct {
+	mp       Graph
+	end      Node
+	maxNodes int
+	used     int
+	open     smaHeap
+	leaves   smaHeap
+	best     map[Node]*smaNode // cheapest copy in memory of each node
+}
+
+// FindPathSMA finds a path from start to end using Simplified
+// Memory-Bounded A* (SMA*), which never keeps more than maxNodes nodes in
+// memory. When the limit is reached the leaf with the highest f cost is
+// pruned and its cost is backed up into its parent so that the subtree
+// can be regenerated if it turns out to be needed after all.
+//
+// A node reached again along a path that's no cheaper and no shorter
+// than a copy of it still in memory is dropped without taking up memory,
+// and the copies in memory are indexed by a map that never has more than
+// maxNodes entries either. Once a copy is pruned though, nothing stops
+// the search from following other paths to the node, so when far fewer
+// nodes fit in memory than can be reached, and in particular when the
+// end can't be reached at all, the search may follow every one of the
+// many paths of equal cost through a grid and take exponential time.
+//
+// If the cheapest path fits in memory (it has fewer than maxNodes nodes)
+// then it is found, otherwise the cheapest path that fits is returned.
+// Since forgotten nodes may be generated many times SMA* can be much
+// slower than A*, but it can search graphs where A* would run out of
+// memory.
+// ErrBudgetExceeded is returned if maxNodes is too small to make any
+// progress and ErrImpossible if no path fits in memory.
+func FindPathSMA(mp Graph, start, end Node, maxNodes int) ([]Node, error) {
+	if maxNodes < 2 {
+		if maxNodes == 1 && start == end {
+			return []Node{start}, nil
+		}
+		return nil, ErrBudgetExceeded
+	}
+	h, err := mp.HeuristicCost(start, end)
+	if err != nil {
+		return nil, err
+	}
+	s := &sma{
+		mp:       mp,
+		end:      end,
+		maxNodes: maxNodes,
+		open:     smaHeap{which: smaOpen},
+		leaves:   smaHeap{which: smaLeaves},
+		best:     make(map[Node]*smaNode),
+	}
+	root := s.newNode(start, nil, -1, 0, h)
+	s.best[start] = root
+	s.open.push(root)
+	s.leaves.push(root)
+	s.used = 1
+	for {
+		if len(s.open.nodes) == 0 {
+			return nil, ErrImpossible
+		}
+		b := s.open.nodes[0]
+		if math.IsInf(b.f, 1) {
+			return nil, ErrImpossible
+		}
+		if b.node == end {
+			return b.path(), nil
+		}
+		if !b.expanded {
+			if err := s.expand(b); err != nil {
+				return nil, err
+			}
+			if len(b.edges) == 0 {
+				// A dead end
+				b.f = math.Inf(1)
+				s.fix(b)
+				if b.parent != nil {
+					b.parent.kidF[b.slot] = b.f
+					s.backup(b.parent)
+				}
+				continue
+			}
+		}
+		i := b.next
+		forgotten := i >= len(b.edges)
+		if !forgotten {
+			b.next++
+		} else {
+			i = b.bestForgotten()
+		}
+		edge := b.edges[i]
+		if s.dominated(b, edge) {
+			// Dropped like a dead end without taking up memory
+			b.kidF[i] = math.Inf(1)
+			if b.next == len(b.edges) {
+				if b.bestForgotten() < 0 {
+					s.open.remove(b)
+				}
+				s.backup(b)
+			}
+			continue
+		}
+		if s.used >= s.maxNodes && !s.prune(b) {
+			return nil, ErrBudgetExceeded
+		}
+		kid, err := s.successor(b, i, edge)
+		if err != nil {
+			return nil, err
+		}
+		if forgotten {
+			kid.f = math.Max(kid.f, b.kidF[i])
+		}
+		b.kids[i] = kid
+		b.kidF[i] = kid.f
+		b.children++
+		s.used++
+		if s.leaves.contains(b) {
+			s.leaves.remove(b)
+		}
+		if b.next == len(b.edges) && b.bestForgotten() < 0 {
+			s.open.remove(b)
+		}
+		s.open.push(kid)
+		s.leaves.push(kid)
+		if best := s.best[kid.node]; best == nil || kid.g < best.g || kid.g == best.g && kid.depth < best.depth {
+			s.best[kid.node] = kid
+		}
+		if b.next == len(b.edges) {
+			s.backup(b)
+		}
+	}
+}
+
+// dominated reports whether a copy of the node at the end of edge that's
+// in memory was reached along a path that's no more expensive and no
+// longer, in which case there's no need to search from it again.
+func (s *sma) dominated(b *smaNode, edge Edge) bool {
+	best := s.best[edge.Node]
+	return best != nil && b.depth+1 >= best.depth && b.g+edge.Cost >= best.g
+}
+
+// bestForgotten returns the slot of the forgotten successor with the
+// lowest f cost, or -1 if there's none that could lead to the end.
+func (n *smaNode) bestForgotten() int {
+	i := -1
+	for j, kid := range n.kids {
+		if kid == nil && !math.IsInf(n.kidF[j], 1) && (i < 0 || n.kidF[j] < n.kidF[i]) {
+			i = j
+		}
+	}
+	return i
+}
+
+func (s *sma) newNode(node Node, parent *smaNode, slot int, g, h float64) *smaNode {
+	n := &smaNode{
+		node:   node,
+		parent: parent,
+		slot:   slot,
+		g:      g,
+		f:      g + h,
+		index:  [2]int{-1, -1},
+	}
+	if parent != nil {
+		n.depth = parent.depth + 1
+		// The f cost of a node is never lower than that of its parent
+		// (pathmax) so backed up costs are kept.
+		n.f = math.Max(n.f, parent.f)
+	}
+	return n
+}
+
+// expand fetches the edges of a node for the first time, skipping any
+// that lead back to a node on the path from the start.
+func (s *sma) expand(b *smaNode) error {
+	edges, err := s.mp.Neighbors(b.node, nil)
+	if err != nil {
+		return err
+	}
+	n := 0
+	for _, e := range edges {
+		if !b.onPath(e.Node) {
+			edges[n] = e
+			n++
+		}
+	}
+	b.edges = edges[:n:n]
+	b.kids = make([]*smaNode, n)
+	b.kidF = make([]float64, n)
+	b.expanded = true
+	return nil
+}
+
+func (s *sma) successor(b *smaNode, slot int, edge Edge) (*smaNode, error) {
+	h, err := s.mp.HeuristicCost(edge.Node, s.end)
+	if err != nil {
+		return nil, err
+	}
+	kid := s.newNode(edge.Node, b, slot, b.g+edge.Cost, h)
+	if kid.node != s.end && kid.depth >= s.maxNodes-1 {
+		// There's no room in memory for a path through this node
+		kid.f = math.Inf(1)
+	}
+	return kid, nil
+}
+
+// prune forgets the worst leaf other than b, remembering its cost in
+// its parent. It reports false if there's nothing to prune. The start
+// is never a leaf while memory is full since it has successors.
+func (s *sma) prune(b *smaNode) bool {
+	isLeaf := s.leaves.contains(b)
+	if isLeaf {
+		s.leaves.remove(b)
+		defer s.leaves.push(b)
+	}
+	if len(s.leaves.nodes) == 0 {
+		return false
+	}
+	w := s.leaves.nodes[0]
+	s.leaves.remove(w)
+	if s.open.contains(w) {
+		s.open.remove(w)
+	}
+	if s.best[w.node] == w {
+		delete(s.best, w.node)
+	}
+	p := w.parent
+	p.kids[w.slot] = nil
+	p.kidF[w.slot] = w.f
+	p.children--
+	s.used--
+	// Only a successor that could lead to the end is worth regenerating
+	if !math.IsInf(w.f, 1) && !s.open.contains(p) {
+		s.open.push(p)
+	}
+	if p.children == 0 {
+		s.leaves.push(p)
+	}
+	return true
+}
+
+// backup sets the f cost of a node whose successors have all been
+// generated to the lowest f cost of its successors, remembered or in
+// memory, and propagates the change to its ancestors.
+func (s *sma) backup(n *smaNode) {
+	for n != nil && n.expanded && n.next == len(n.edges) {
+		f := math.Inf(1)
+		for _, kf := range n.kidF {
+			f = math.Min(f, kf)
+		}
+		if f == n.f {
+			return
+		}
+		n.f = f
+		s.fix(n)
+		if n.parent == nil {
+			return
+		}
+		n.parent.kidF[n.slot] = f
+		n = n.parent
+	}
+}
+
+func (s *sma) fix(n *smaNode) {
+	s.open.fix(n)
+	s.leaves.fix(n)
+}
+
+func (n *smaNode) onPath(node Node) bool {
+	for ; n != nil; n = n.parent {
+		if n.node == node {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *smaNode) path() []Node {
+	path := make([]Node, n.depth+1)
+	for ; n != nil; n = n.parent {
+		path[n.depth] = n.node
+	}
+	return path
+}
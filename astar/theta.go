@@ -0,0 +1,32 @@
+package astar
+
+// FindPathTheta finds an any-angle path from start to end using Theta*.
+// When relaxing an edge, if the parent of the current node has line of
+// sight to the neighbor then the neighbor is connected directly to that
+// parent at a cost of HeuristicCost(parent, neighbor). The result is a
+// path with fewer and longer segments than a grid-constrained path.
+// Consecutive nodes in the returned path are not necessarily neighbors.
+// Theta* paths are usually but not always the shortest any-angle path.
+func FindPathTheta(mp LineOfSightGraph, start, end Node) ([]Node, error) {
+	path, _, err := findPath(mp, start, end, config{los: mp})
+	return path, err
+}
+
+// SmoothPath removes interior nodes of a path when the node before has
+// line of sight to the node after, collapsing runs of nodes into straight
+// segments. The first and last nodes are always kept. A new slice is
+// returned and the given path is not modified.
+func SmoothPath(mp LineOfSightGraph, path []Node) []Node {
+	if len(path) <= 2 {
+		return append([]Node(nil), path...)
+	}
+	smooth := []Node{path[0]}
+	anchor := path[0]
+	for i := 1; i < len(path)-1; i++ {
+		if !mp.LineOfSight(anchor, path[i+1]) {
+			anchor = path[i]
+			smooth = append(smooth, anchor)
+		}
+	}
+	return append(smooth, path[len(path)-1])
+}
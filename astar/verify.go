@@ -0,0 +1,63 @@
+package astar
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidPath is wrapped by the error VerifyPath returns for a path
+// that can't be followed.
+var ErrInvalidPath = errors.New("astar: invalid path")
+
+// MissingEdgeError identifies two consecutive nodes of a path with no
+// edge between them.
+type MissingEdgeError struct {
+	From, To Node
+	Index    int // index in the path of To
+}
+
+func (e *MissingEdgeError) Error() string {
+	return fmt.Sprintf("astar: no edge from %d to %d at index %d of path", e.From, e.To, e.Index)
+}
+
+func (e *MissingEdgeError) Unwrap() error {
+	return ErrInvalidPath
+}
+
+// VerifyPath checks that every node of a path has an edge to the next
+// one according to Neighbors and returns the total cost of following it,
+// using the cheapest edge where there's more than one and including the
+// cost of entering each node if the graph implements NodeCost. It
+// returns a *MissingEdgeError for the first missing edge and
+// ErrInvalidPath for an empty path. It's meant as an oracle for testing
+// path finding, since the cost should match what the search reported.
+func VerifyPath(mp Graph, path []Node) (float64, error) {
+	if len(path) == 0 {
+		return 0, ErrInvalidPath
+	}
+	cost := 0.0
+	var edges []Edge
+	for i := 1; i < len(path); i++ {
+		var err error
+		edges, err = mp.Neighbors(path[i-1], edges[:0])
+		if err != nil {
+			return 0, err
+		}
+		found := false
+		best := 0.0
+		for _, e := range edges {
+			if e.Node == path[i] && (!found || e.Cost < best) {
+				best = e.Cost
+				found = true
+			}
+		}
+		if !found {
+			return 0, &MissingEdgeError{From: path[i-1], To: path[i], Index: i}
+		}
+		cost += best
+		if nc, ok := mp.(NodeCost); ok {
+			cost += nc.NodeCost(path[i])
+		}
+	}
+	return cost, nil
+}
@@ -0,0 +1,43 @@
+package astar
+
+import (
+	"fmt"
+)
+
+// LegError is returned by FindPathWaypoints when no path could be
+// found for one of the legs between consecutive waypoints.
+type LegError struct {
+	Leg      int  // index of the leg (leg i goes from waypoint i to i+1)
+	From, To Node // waypoints at either end of the leg
+	Err      error
+}
+
+func (e *LegError) Error() string {
+	return fmt.Sprintf("astar: leg %d from %d to %d: %s", e.Leg, e.From, e.To, e.Err)
+}
+
+func (e *LegError) Unwrap() error {
+	return e.Err
+}
+
+// FindPathWaypoints finds the optimal path that visits each of the
+// waypoints in order, returning the concatenated path and its total
+// cost. If a leg between two waypoints can't be completed then a
+// *LegError identifying the leg is returned.
+func FindPathWaypoints(mp Graph, waypoints []Node) ([]Node, float64, error) {
+	if len(waypoints) == 0 {
+		return nil, 0, ErrImpossible
+	}
+	path := []Node{waypoints[0]}
+	total := 0.0
+	for i := 1; i < len(waypoints); i++ {
+		leg, cost, err := FindPathCost(mp, waypoints[i-1], waypoints[i])
+		if err != nil {
+			return nil, 0, &LegError{Leg: i - 1, From: waypoints[i-1], To: waypoints[i], Err: err}
+		}
+		// The first node of the leg is the last node of the path so far
+		path = append(path, leg[1:]...)
+		total += cost
+	}
+	return path, total, nil
+}
@@ -0,0 +1,162 @@
+package astar
+
+import (
+	"errors"
+)
+
+var errNoEdge = errors.New("astar: path contains nodes that are not connected")
+
+// filteredGraph hides a set of nodes and edges of the wrapped graph.
+type filteredGraph struct {
+	Graph
+	nodes map[Node]bool
+	edges map[[2]Node]bool
+}
+
+func (g *filteredGraph) Neighbors(node Node, edges []Edge) ([]Edge, error) {
+	if g.nodes[node] {
+		return edges, nil
+	}
+	start := len(edges)
+	edges, err := g.Graph.Neighbors(node, edges)
+	if err != nil {
+		return nil, err
+	}
+	// Filter in place
+	out := edges[:start]
+	for _, e := range edges[start:] {
+		if !g.nodes[e.Node] && !g.edges[[2]Node{node, e.Node}] {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// edgeCost returns the cost of the cheapest edge from a to b.
+func edgeCost(mp Graph, a, b Node, edges []Edge) (float64, []Edge, error) {
+	edges, err := mp.Neighbors(a, edges[:0])
+	if err != nil {
+		return 0, edges, err
+	}
+	found := false
+	var cost float64
+	for _, e := range edges {
+		if e.Node == b && (!found || e.Cost < cost) {
+			cost = e.Cost
+			found = true
+		}
+	}
+	if !found {
+		return 0, edges, errNoEdge
+	}
+	return cost, edges, nil
+}
+
+type costedPath struct {
+	path []Node
+	cost float64
+}
+
+func equalPaths(a, b []Node) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// FindKPaths returns up to k loopless paths from start to end in order
+// of increasing cost using Yen's algorithm. Fewer than k paths are
+// returned if fewer exist. If no path exists at all then ErrImpossible
+// is returned.
+func FindKPaths(mp Graph, start, end Node, k int) ([][]Node, error) {
+	if k <= 0 {
+		return nil, nil
+	}
+	path, cost, err := FindPathCost(mp, start, end)
+	if err != nil {
+		return nil, err
+	}
+	found := []costedPath{{path, cost}}
+	var candidates []costedPath
+
+	filtered := &filteredGraph{
+		Graph: mp,
+		nodes: make(map[Node]bool),
+		edges: make(map[[2]Node]bool),
+	}
+	edgeSlice := make([]Edge, 0, 8)
+	for len(found) < k {
+		prev := found[len(found)-1].path
+		rootCost := 0.0
+		for i := 0; i < len(prev)-1; i++ {
+			spurNode := prev[i]
+			rootPath := prev[:i+1]
+
+			for n := range filtered.nodes {
+				delete(filtered.nodes, n)
+			}
+			for e := range filtered.edges {
+				delete(filtered.edges, e)
+			}
+			// Remove the next edge of every known path sharing this root so
+			// the spur path has to deviate, and remove the root nodes so the
+			// resulting path is loopless.
+			for _, p := range found {
+				if len(p.path) > i+1 && equalPaths(p.path[:i+1], rootPath) {
+					filtered.edges[[2]Node{p.path[i], p.path[i+1]}] = true
+				}
+			}
+			for _, n := range rootPath[:i] {
+				filtered.nodes[n] = true
+			}
+
+			spurPath, spurCost, err := FindPathCost(filtered, spurNode, end)
+			if err == nil {
+				total := make([]Node, 0, i+len(spurPath))
+				total = append(total, rootPath[:i]...)
+				total = append(total, spurPath...)
+				dup := false
+				for _, c := range candidates {
+					if equalPaths(c.path, total) {
+						dup = true
+						break
+					}
+				}
+				if !dup {
+					candidates = append(candidates, costedPath{total, rootCost + spurCost})
+				}
+			} else if !errors.Is(err, ErrImpossible) {
+				return nil, err
+			}
+
+			var c float64
+			c, edgeSlice, err = edgeCost(mp, prev[i], prev[i+1], edgeSlice)
+			if err != nil {
+				return nil, err
+			}
+			rootCost += c
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		best := 0
+		for i, c := range candidates {
+			if c.cost < candidates[best].cost {
+				best = i
+			}
+		}
+		found = append(found, candidates[best])
+		candidates = append(candidates[:best], candidates[best+1:]...)
+	}
+
+	paths := make([][]Node, len(found))
+	for i, p := range found {
+		paths[i] = p.path
+	}
+	return paths, nil
+}
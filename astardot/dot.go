@@ -0,0 +1,79 @@
+// Package astardot writes the result of an astar search in the Graphviz
+// DOT language so it can be rendered to see how the search unfolded.
+package astardot
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/samuel/go-astar/astar"
+)
+
+// WriteDOT writes the search tree, as returned by astar.FindPathTree, as
+// a directed graph with an edge from each node to the nodes reached from
+// it. Nodes on path are filled and edges between them are highlighted.
+// Each node with a cost in costs, as returned by astar.FindPathExplored,
+// is labelled with it and each edge between two such nodes with the
+// difference, which is the cost of the edge. The path and costs may be
+// nil.
+func WriteDOT(w io.Writer, path []astar.Node, tree map[astar.Node]astar.Node, costs map[astar.Node]float64) error {
+	nodes := make(map[astar.Node]bool)
+	for child, parent := range tree {
+		nodes[child] = true
+		nodes[parent] = true
+	}
+	onPath := make(map[astar.Node]bool)
+	pathEdges := make(map[[2]astar.Node]bool)
+	for i, n := range path {
+		nodes[n] = true
+		onPath[n] = true
+		if i > 0 {
+			pathEdges[[2]astar.Node{path[i-1], n}] = true
+		}
+	}
+	sorted := make([]astar.Node, 0, len(nodes))
+	for n := range nodes {
+		sorted = append(sorted, n)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph search {")
+	for _, n := range sorted {
+		label := strconv.FormatInt(int64(n), 10)
+		if c, ok := costs[n]; ok {
+			label += `\n` + formatCost(c)
+		}
+		attrs := []string{`label="` + label + `"`}
+		if onPath[n] {
+			attrs = append(attrs, "style=filled", "fillcolor=palegreen")
+		}
+		fmt.Fprintf(bw, "\t%d [%s];\n", n, strings.Join(attrs, ", "))
+	}
+	for _, child := range sorted {
+		parent, ok := tree[child]
+		if !ok {
+			continue
+		}
+		var attrs []string
+		pc, okp := costs[parent]
+		cc, okc := costs[child]
+		if okp && okc {
+			attrs = append(attrs, `label="`+formatCost(cc-pc)+`"`)
+		}
+		if pathEdges[[2]astar.Node{parent, child}] {
+			attrs = append(attrs, "color=green", "penwidth=2")
+		}
+		fmt.Fprintf(bw, "\t%d -> %d [%s];\n", parent, child, strings.Join(attrs, ", "))
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+func formatCost(c float64) string {
+	return strconv.FormatFloat(c, 'g', 6, 64)
+}
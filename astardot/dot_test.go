@@ -0,0 +1,58 @@
+package astardot
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/samuel/go-astar/astar"
+)
+
+func TestWriteDOT(t *testing.T) {
+	mp := astar.NewAdjacencyGraph(map[astar.Node][]astar.Edge{
+		0: {{Node: 1, Cost: 1}, {Node: 2, Cost: 4}},
+		1: {{Node: 2, Cost: 1}, {Node: 4, Cost: 5}},
+		2: {{Node: 3, Cost: 1.5}},
+	}, nil)
+	path, tree, err := astar.FindPathTree(mp, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, costs, err := astar.FindPathExplored(mp, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteDOT(&buf, path, tree, costs); err != nil {
+		t.Fatal(err)
+	}
+	expected := `digraph search {
+	0 [label="0\n0", style=filled, fillcolor=palegreen];
+	1 [label="1\n1", style=filled, fillcolor=palegreen];
+	2 [label="2\n2", style=filled, fillcolor=palegreen];
+	3 [label="3\n3.5", style=filled, fillcolor=palegreen];
+	4 [label="4"];
+	0 -> 1 [label="1", color=green, penwidth=2];
+	1 -> 2 [label="1", color=green, penwidth=2];
+	2 -> 3 [label="1.5", color=green, penwidth=2];
+	1 -> 4 [];
+}
+`
+	if buf.String() != expected {
+		t.Fatalf("Expected:\n%s\ninstead of:\n%s", expected, buf.String())
+	}
+}
+
+type failingWriter struct{}
+
+var errWrite = errors.New("write failed")
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errWrite
+}
+
+func TestWriteDOTError(t *testing.T) {
+	if err := WriteDOT(failingWriter{}, []astar.Node{0, 1}, nil, nil); err != errWrite {
+		t.Fatalf("Expected the write error instead of %v", err)
+	}
+}
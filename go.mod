@@ -1,3 +1,3 @@
 module github.com/samuel/go-astar
 
-go 1.17
+go 1.23
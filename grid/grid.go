@@ -0,0 +1,217 @@
+// Package grid provides a graph of the cells of a rectangular grid for
+// path finding with astar, along with the usual distance heuristics.
+package grid
+
+import (
+	"math"
+
+	"github.com/samuel/go-astar/astar"
+)
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// ManhattanDistance is the cost of moving dx cells horizontally and dy
+// cells vertically one straight step at a time. It's the exact distance
+// on an open 4-connected grid.
+func ManhattanDistance(dx, dy int) float64 {
+	return float64(abs(dx) + abs(dy))
+}
+
+// EuclideanDistance is the length of a straight line across dx cells
+// horizontally and dy cells vertically. It's admissible for any grid
+// with straight moves costing 1 and diagonal moves sqrt(2) but
+// underestimates more than OctileDistance.
+func EuclideanDistance(dx, dy int) float64 {
+	return math.Sqrt(float64(dx*dx + dy*dy))
+}
+
+// OctileDistance is the cost of moving dx cells horizontally and dy
+// cells vertically when a straight step costs straight and a diagonal
+// step costs diagonal. It's the exact distance on an open 8-connected
+// grid as long as a diagonal step costs no more than two straight ones.
+func OctileDistance(dx, dy int, straight, diagonal float64) float64 {
+	dx, dy = abs(dx), abs(dy)
+	if dx < dy {
+		dx, dy = dy, dx
+	}
+	return straight*float64(dx-dy) + diagonal*float64(dy)
+}
+
+// ChebyshevDistance is the number of steps needed to move dx cells
+// horizontally and dy cells vertically when diagonal steps cost the
+// same as straight ones. It's the exact distance on such an open
+// 8-connected grid, and admissible but less accurate than
+// OctileDistance when diagonal steps cost more.
+func ChebyshevDistance(dx, dy int) float64 {
+	return float64(max(abs(dx), abs(dy)))
+}
+
+// Grid is a graph of the cells of a Width by Height grid. Node
+// y*Width+x is the cell at (x, y). Moving to a horizontally or
+// vertically adjacent cell costs 1 and, if Diagonal is set, moving to a
+// diagonally adjacent cell costs sqrt(2). Only walkable cells can be
+// entered.
+//
+// A Grid also implements astar.DenseGraph, astar.Validator,
+// astar.Undirected and, with EuclideanDistance as its Heuristic,
+// astar.LineOfSightGraph.
+type Grid struct {
+	Width, Height int
+	// Walkable reports whether the cell at (x, y) can be entered. It's
+	// only called for cells inside the grid. If it's nil every cell is
+	// walkable.
+	Walkable func(x, y int) bool
+	// Diagonal allows moving to the 8 surrounding cells instead of only
+	// the 4 horizontally and vertically adjacent ones.
+	Diagonal bool
+	// DisallowCornerCutting only allows a diagonal move when both of the
+	// cells it passes between are walkable, so paths don't clip the
+	// corners of obstacles.
+	DisallowCornerCutting bool
+	// Heuristic returns the estimated cost of moving dx cells
+	// horizontally and dy cells vertically. If it's nil OctileDistance
+	// is used for diagonal grids and ManhattanDistance otherwise, which
+	// are exact on an open grid.
+	Heuristic func(dx, dy int) float64
+	// CrossProductTieBreak prefers, among cells with the same estimated
+	// path cost, those closer to the straight line from Start to the
+	// goal, which on open 8-connected grids keeps the search from
+	// exploring the many paths of equal cost. It works by lowering the
+	// heuristic cost of cells less than half a cell from the line by up
+	// to a thousandth of a step, less for cells further from it, so the
+	// search follows the line while other cells tie with the goal, which
+	// wins the tie. The heuristic stays admissible and is zero at the goal
+	// but it's no longer consistent: a step toward the line can lower the
+	// heuristic cost by slightly more than the step costs, so a cell may
+	// be expanded again if it's later reached more cheaply.
+	CrossProductTieBreak bool
+	// Start is the start of the search, used by CrossProductTieBreak.
+	Start astar.Node
+}
+
+// tieBreakScale is the most CrossProductTieBreak lowers the heuristic.
+const tieBreakScale = 1e-3
+
+// Index returns the node for the cell at (x, y).
+func (g *Grid) Index(x, y int) astar.Node {
+	return astar.PackXY(x, y, g.Width)
+}
+
+// XY returns the coordinates of the cell for a node.
+func (g *Grid) XY(node astar.Node) (x, y int) {
+	return astar.UnpackXY(node, g.Width)
+}
+
+func (g *Grid) inBounds(x, y int) bool {
+	return x >= 0 && y >= 0 && x < g.Width && y < g.Height
+}
+
+func (g *Grid) walkable(x, y int) bool {
+	return g.inBounds(x, y) && (g.Walkable == nil || g.Walkable(x, y))
+}
+
+// straight and diagonal are the moves to neighboring cells
+var (
+	straight = [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	diagonal = [4][2]int{{-1, -1}, {1, -1}, {-1, 1}, {1, 1}}
+)
+
+func (g *Grid) Neighbors(node astar.Node, edges []astar.Edge) ([]astar.Edge, error) {
+	if !g.Valid(node) {
+		return edges, nil
+	}
+	x, y := g.XY(node)
+	for _, d := range straight {
+		if g.walkable(x+d[0], y+d[1]) {
+			edges = append(edges, astar.Edge{Node: g.Index(x+d[0], y+d[1]), Cost: 1})
+		}
+	}
+	if !g.Diagonal {
+		return edges, nil
+	}
+	for _, d := range diagonal {
+		if !g.walkable(x+d[0], y+d[1]) {
+			continue
+		}
+		if g.DisallowCornerCutting && (!g.walkable(x+d[0], y) || !g.walkable(x, y+d[1])) {
+			continue
+		}
+		edges = append(edges, astar.Edge{Node: g.Index(x+d[0], y+d[1]), Cost: math.Sqrt2})
+	}
+	return edges, nil
+}
+
+func (g *Grid) HeuristicCost(start, end astar.Node) (float64, error) {
+	startX, startY := g.XY(start)
+	endX, endY := g.XY(end)
+	dx, dy := endX-startX, endY-startY
+	var h float64
+	switch {
+	case g.Heuristic != nil:
+		h = g.Heuristic(dx, dy)
+	case g.Diagonal:
+		h = OctileDistance(dx, dy, 1, math.Sqrt2)
+	default:
+		h = ManhattanDistance(dx, dy)
+	}
+	if g.CrossProductTieBreak && h > 0 {
+		if d := g.lineDistance(start, end); d < 0.5 {
+			h -= tieBreakScale * (1 - 2*d)
+		}
+	}
+	return h, nil
+}
+
+// lineDistance is the distance of a node from the straight line through
+// Start and end in cells, from the cross product of the vectors from the
+// node and from Start to end.
+func (g *Grid) lineDistance(node, end astar.Node) float64 {
+	x, y := g.XY(node)
+	sx, sy := g.XY(g.Start)
+	ex, ey := g.XY(end)
+	dx1, dy1 := float64(x-ex), float64(y-ey)
+	dx2, dy2 := float64(sx-ex), float64(sy-ey)
+	n := math.Hypot(dx2, dy2)
+	if n == 0 {
+		return 0
+	}
+	return math.Abs(dx1*dy2-dx2*dy1) / n
+}
+
+// NodeCount implements astar.DenseGraph.
+func (g *Grid) NodeCount() int {
+	return g.Width * g.Height
+}
+
+// Valid reports whether a node is a cell of the grid.
+func (g *Grid) Valid(node astar.Node) bool {
+	return node >= 0 && node < astar.Node(g.NodeCount())
+}
+
+// Snapshot returns a copy of the grid with the walkability of every cell
+// as it is at the time of the call, so a search can run on the copy while
+// the cells are being changed, such as by another goroutine. The copy
+// calls Walkable only from Snapshot itself and is never modified, so it
+// may be searched by any number of goroutines at once.
+func (g *Grid) Snapshot() *Grid {
+	walkable := make([]bool, g.NodeCount())
+	for i := range walkable {
+		x, y := g.XY(astar.Node(i))
+		walkable[i] = g.walkable(x, y)
+	}
+	snap := *g
+	width := g.Width
+	snap.Walkable = func(x, y int) bool {
+		return walkable[y*width+x]
+	}
+	return &snap
+}
+
+// Undirected implements astar.Undirected since every move can be made in
+// reverse at the same cost.
+func (g *Grid) Undirected() {}
@@ -0,0 +1,405 @@
+package grid
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/samuel/go-astar/astar"
+)
+
+// testGrid returns a grid with the walls marked by 1 in cells
+func testGrid(width int, cells []int) *Grid {
+	return &Grid{
+		Width:  width,
+		Height: len(cells) / width,
+		Walkable: func(x, y int) bool {
+			return cells[y*width+x] == 0
+		},
+	}
+}
+
+func TestIndexXY(t *testing.T) {
+	g := &Grid{Width: 7, Height: 5}
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			n := g.Index(x, y)
+			if n != astar.Node(y*7+x) {
+				t.Fatalf("Expected node %d for (%d, %d) instead of %d", y*7+x, x, y, n)
+			}
+			if nx, ny := g.XY(n); nx != x || ny != y {
+				t.Fatalf("Expected (%d, %d) for node %d instead of (%d, %d)", x, y, n, nx, ny)
+			}
+		}
+	}
+}
+
+func TestNeighbors(t *testing.T) {
+	g := testGrid(3, []int{
+		0, 1, 0,
+		0, 0, 0,
+		0, 0, 0,
+	})
+	cases := []struct {
+		diagonal, noCorners bool
+		node                astar.Node
+		expected            int
+	}{
+		{false, false, 4, 3},
+		{true, false, 4, 7},
+		{false, false, 0, 1},
+		{true, false, 0, 2},
+		// Moving from (0, 0) to (1, 1) cuts the corner of the wall
+		{true, true, 0, 1},
+		{true, true, 4, 5},
+		{true, true, 8, 3},
+		// Outside of the grid
+		{true, false, 9, 0},
+		{true, false, -1, 0},
+	}
+	for _, c := range cases {
+		g.Diagonal = c.diagonal
+		g.DisallowCornerCutting = c.noCorners
+		edges, err := g.Neighbors(c.node, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(edges) != c.expected {
+			t.Fatalf("Expected %d neighbors for %d (diagonal %t, no corners %t) instead of %v", c.expected, c.node, c.diagonal, c.noCorners, edges)
+		}
+		for _, e := range edges {
+			x, y := g.XY(e.Node)
+			if !g.walkable(x, y) {
+				t.Fatalf("Neighbor %d of %d is not walkable", e.Node, c.node)
+			}
+		}
+	}
+}
+
+// sampleCells is a 10x10 grid with walls marked by 1
+var sampleCells = []int{
+	0, 0, 0, 0, 1, 0, 0, 0, 0, 0,
+	0, 1, 1, 0, 1, 0, 0, 0, 0, 0,
+	0, 0, 1, 0, 1, 0, 0, 0, 0, 0,
+	0, 0, 1, 0, 1, 0, 0, 0, 0, 0,
+	0, 0, 1, 0, 1, 0, 0, 1, 1, 0,
+	0, 0, 1, 0, 1, 0, 0, 0, 1, 0,
+	0, 0, 1, 0, 1, 0, 0, 1, 0, 0,
+	1, 1, 1, 0, 1, 0, 1, 0, 0, 0,
+	0, 0, 0, 0, 1, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+}
+
+func TestFindPath(t *testing.T) {
+	cases := []struct {
+		diagonal, noCorners bool
+		heuristic           func(dx, dy int) float64
+		cost                float64
+	}{
+		{false, false, nil, 29},
+		{false, false, EuclideanDistance, 29},
+		{true, false, nil, 15 + 7*math.Sqrt2},
+		{true, false, EuclideanDistance, 15 + 7*math.Sqrt2},
+		{true, true, nil, 23 + 3*math.Sqrt2},
+		{true, false, ChebyshevDistance, 15 + 7*math.Sqrt2},
+		{true, false, func(dx, dy int) float64 { return OctileDistance(dx, dy, 1, math.Sqrt2) }, 15 + 7*math.Sqrt2},
+	}
+	for _, c := range cases {
+		g := testGrid(10, sampleCells)
+		g.Diagonal = c.diagonal
+		g.DisallowCornerCutting = c.noCorners
+		g.Heuristic = c.heuristic
+		start, end := g.Index(0, 5), g.Index(9, 3)
+		nodes := make([]astar.Node, g.NodeCount())
+		for i := range nodes {
+			nodes[i] = astar.Node(i)
+		}
+		violations, err := astar.CheckHeuristic(g, nodes, end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(violations) != 0 {
+			t.Fatalf("Expected a consistent heuristic instead of %+v", violations[0])
+		}
+		path, cost, err := astar.FindPathCost(g, start, end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path[0] != start || path[len(path)-1] != end {
+			t.Fatalf("Expected path from %d to %d instead of %v", start, end, path)
+		}
+		if math.Abs(cost-c.cost) > 1e-9 {
+			t.Fatalf("Expected cost %f (diagonal %t, no corners %t) instead of %f", c.cost, c.diagonal, c.noCorners, cost)
+		}
+	}
+}
+
+func TestHeuristics(t *testing.T) {
+	cases := []struct {
+		dx, dy                                  int
+		manhattan, euclidean, octile, chebyshev float64
+	}{
+		{0, 0, 0, 0, 0, 0},
+		{3, 4, 7, 5, 1 + 3*math.Sqrt2, 4},
+		{-3, 4, 7, 5, 1 + 3*math.Sqrt2, 4},
+		{0, -2, 2, 2, 2, 2},
+		{5, -5, 10, 5 * math.Sqrt2, 5 * math.Sqrt2, 5},
+	}
+	for _, c := range cases {
+		if d := ManhattanDistance(c.dx, c.dy); d != c.manhattan {
+			t.Errorf("Expected Manhattan distance %f for (%d, %d) instead of %f", c.manhattan, c.dx, c.dy, d)
+		}
+		if d := EuclideanDistance(c.dx, c.dy); math.Abs(d-c.euclidean) > 1e-9 {
+			t.Errorf("Expected Euclidean distance %f for (%d, %d) instead of %f", c.euclidean, c.dx, c.dy, d)
+		}
+		if d := OctileDistance(c.dx, c.dy, 1, math.Sqrt2); math.Abs(d-c.octile) > 1e-9 {
+			t.Errorf("Expected octile distance %f for (%d, %d) instead of %f", c.octile, c.dx, c.dy, d)
+		}
+		if d := ChebyshevDistance(c.dx, c.dy); d != c.chebyshev {
+			t.Errorf("Expected Chebyshev distance %f for (%d, %d) instead of %f", c.chebyshev, c.dx, c.dy, d)
+		}
+	}
+	if d := OctileDistance(3, 4, 2, 3); d != 11 {
+		t.Errorf("Expected octile distance 11 with costs 2 and 3 instead of %f", d)
+	}
+}
+
+func TestOctileExpandsFewerNodes(t *testing.T) {
+	g := testGrid(10, sampleCells)
+	g.Diagonal = true
+	start, end := g.Index(5, 0), g.Index(9, 9)
+	_, octile, err := astar.FindPathStats(g, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Heuristic = EuclideanDistance
+	_, euclidean, err := astar.FindPathStats(g, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if octile.NodesExpanded >= euclidean.NodesExpanded {
+		t.Fatalf("Expected octile distance to expand fewer than the %d nodes Euclidean distance did instead of %d", euclidean.NodesExpanded, octile.NodesExpanded)
+	}
+}
+
+// BenchmarkHeuristic reports the nodes expanded by each heuristic on the
+// sample grid.
+func BenchmarkHeuristic(b *testing.B) {
+	heuristics := []struct {
+		name string
+		fn   func(dx, dy int) float64
+	}{
+		{"Octile", nil},
+		{"Euclidean", EuclideanDistance},
+		{"Chebyshev", ChebyshevDistance},
+	}
+	for _, h := range heuristics {
+		b.Run(h.name, func(b *testing.B) {
+			g := testGrid(10, sampleCells)
+			g.Diagonal = true
+			g.Heuristic = h.fn
+			start, end := g.Index(5, 0), g.Index(9, 9)
+			var stats astar.Stats
+			for i := 0; i < b.N; i++ {
+				var err error
+				_, stats, err = astar.FindPathStats(g, start, end)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(stats.NodesExpanded), "expanded/op")
+		})
+	}
+}
+
+// openGridSearch searches an open 50x50 8-connected grid with or without
+// the cross product tie break.
+func openGridSearch(tb testing.TB, tieBreak bool) ([]astar.Node, float64, astar.Stats) {
+	g := &Grid{Width: 50, Height: 50, Diagonal: true}
+	start, end := g.Index(2, 10), g.Index(45, 40)
+	g.CrossProductTieBreak = tieBreak
+	g.Start = start
+	path, stats, err := astar.FindPathStats(g, start, end)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	cost := 0.0
+	for i := 1; i < len(path); i++ {
+		x0, y0 := g.XY(path[i-1])
+		x1, y1 := g.XY(path[i])
+		cost += EuclideanDistance(x1-x0, y1-y0)
+	}
+	return path, cost, stats
+}
+
+func TestCrossProductTieBreak(t *testing.T) {
+	_, cost, plain := openGridSearch(t, false)
+	_, tbCost, tb := openGridSearch(t, true)
+	if math.Abs(cost-tbCost) > 1e-9 {
+		t.Fatalf("Expected the tie break to find a path of cost %f instead of %f", cost, tbCost)
+	}
+	if tb.NodesExpanded >= plain.NodesExpanded {
+		t.Fatalf("Expected the tie break to expand fewer than %d nodes instead of %d", plain.NodesExpanded, tb.NodesExpanded)
+	}
+
+	// The heuristic cost of the goal is still zero
+	g := &Grid{Width: 10, Height: 10, Diagonal: true, CrossProductTieBreak: true}
+	start, end := g.Index(1, 2), g.Index(8, 6)
+	g.Start = start
+	if _, err := astar.FindPathOpts(g, start, end, astar.Options{CheckGoalHeuristic: true}); err != nil {
+		t.Fatal(err)
+	}
+	if h, _ := g.HeuristicCost(end, end); h != 0 {
+		t.Fatalf("Expected a heuristic cost of 0 at the goal instead of %g", h)
+	}
+}
+
+// BenchmarkCrossProductTieBreak reports the nodes expanded on an open grid
+// with and without the tie break.
+func BenchmarkCrossProductTieBreak(b *testing.B) {
+	for _, tieBreak := range []bool{false, true} {
+		name := "Plain"
+		if tieBreak {
+			name = "TieBreak"
+		}
+		b.Run(name, func(b *testing.B) {
+			var stats astar.Stats
+			for i := 0; i < b.N; i++ {
+				_, _, stats = openGridSearch(b, tieBreak)
+			}
+			b.ReportMetric(float64(stats.NodesExpanded), "expanded/op")
+		})
+	}
+}
+
+func TestCompressPath(t *testing.T) {
+	g := &Grid{Width: 10, Height: 10}
+	xy := func(points ...[2]int) []astar.Node {
+		var path []astar.Node
+		for _, p := range points {
+			path = append(path, g.Index(p[0], p[1]))
+		}
+		return path
+	}
+	cases := []struct {
+		path     []astar.Node
+		segments []Segment
+	}{
+		{nil, nil},
+		{xy([2]int{3, 3}), nil},
+		{
+			xy([2]int{0, 0}, [2]int{1, 0}, [2]int{2, 0}, [2]int{3, 1}, [2]int{4, 2}, [2]int{4, 3}),
+			[]Segment{
+				{g.Index(0, 0), g.Index(2, 0), 2},
+				{g.Index(2, 0), g.Index(4, 2), 2},
+				{g.Index(4, 2), g.Index(4, 3), 1},
+			},
+		},
+		// Moves of different lengths in the same direction are merged
+		{
+			xy([2]int{0, 5}, [2]int{2, 5}, [2]int{3, 5}, [2]int{3, 4}, [2]int{3, 5}),
+			[]Segment{
+				{g.Index(0, 5), g.Index(3, 5), 2},
+				{g.Index(3, 5), g.Index(3, 4), 1},
+				{g.Index(3, 4), g.Index(3, 5), 1},
+			},
+		},
+	}
+	for _, c := range cases {
+		if segments := CompressPath(c.path, g.Width); !reflect.DeepEqual(segments, c.segments) {
+			t.Errorf("Expected %v for %v instead of %v", c.segments, c.path, segments)
+		}
+	}
+}
+
+func TestGridLineOfSight(t *testing.T) {
+	g := testGrid(7, []int{
+		0, 0, 0, 0, 0, 0, 0,
+		0, 0, 1, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 1, 0,
+		0, 0, 0, 0, 1, 0, 0,
+		0, 0, 0, 0, 0, 0, 0,
+	})
+	cases := []struct {
+		name      string
+		a, b      [2]int
+		noCorners bool
+		expected  bool
+	}{
+		{"same cell", [2]int{3, 3}, [2]int{3, 3}, false, true},
+		{"horizontal", [2]int{0, 0}, [2]int{6, 0}, false, true},
+		{"horizontal through a wall", [2]int{0, 1}, [2]int{6, 1}, false, false},
+		{"vertical", [2]int{3, 0}, [2]int{3, 4}, false, true},
+		{"vertical through a wall", [2]int{2, 0}, [2]int{2, 4}, false, false},
+		{"diagonal", [2]int{0, 0}, [2]int{4, 4}, false, true},
+		{"diagonal through a wall", [2]int{0, 3}, [2]int{3, 0}, false, false},
+		{"shallow", [2]int{0, 0}, [2]int{6, 1}, false, true},
+		{"shallow through a wall", [2]int{0, 2}, [2]int{6, 3}, false, false},
+		// Between the walls at (4, 3) and (5, 2)
+		{"between two walls", [2]int{3, 1}, [2]int{6, 4}, false, false},
+		// Past the corner of the wall at (4, 3)
+		{"grazing a corner", [2]int{3, 3}, [2]int{5, 1}, false, true},
+		{"grazing a corner without cutting", [2]int{3, 3}, [2]int{5, 1}, true, false},
+		{"wall at the end", [2]int{0, 0}, [2]int{2, 1}, false, false},
+	}
+	for _, c := range cases {
+		g.DisallowCornerCutting = c.noCorners
+		a, b := g.Index(c.a[0], c.a[1]), g.Index(c.b[0], c.b[1])
+		if los := GridLineOfSight(g, a, b); los != c.expected {
+			t.Errorf("%s: expected line of sight %t from %v to %v", c.name, c.expected, c.a, c.b)
+		}
+		if los := g.LineOfSight(b, a); los != c.expected {
+			t.Errorf("%s: expected line of sight %t from %v to %v", c.name, c.expected, c.b, c.a)
+		}
+	}
+	if GridLineOfSight(g, 0, astar.Node(g.NodeCount())) {
+		t.Error("Expected no line of sight to a node outside of the grid")
+	}
+}
+
+func TestGridSmoothPath(t *testing.T) {
+	g := testGrid(10, sampleCells)
+	g.Diagonal = true
+	g.Heuristic = EuclideanDistance
+	start, end := g.Index(0, 5), g.Index(9, 3)
+	path, err := astar.FindPath(g, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	smooth := astar.SmoothPath(g, path)
+	if len(smooth) >= len(path) {
+		t.Fatalf("Expected smoothing to remove nodes from %v instead of %v", path, smooth)
+	}
+	for i := 1; i < len(smooth); i++ {
+		if !g.LineOfSight(smooth[i-1], smooth[i]) {
+			t.Fatalf("Expected line of sight from %d to %d", smooth[i-1], smooth[i])
+		}
+	}
+	if _, err := astar.FindPathTheta(g, start, end); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	cells := append([]int(nil), sampleCells...)
+	g := testGrid(10, cells)
+	g.Diagonal = true
+	start, end := g.Index(0, 5), g.Index(9, 3)
+	snap := g.Snapshot()
+	_, cost, err := astar.FindPathCost(g, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Close the gap at the bottom of the wall in column 4
+	cells[g.Index(4, 9)] = 1
+	if _, err := astar.FindPath(g, start, end); err == nil {
+		t.Fatal("Expected no path once the wall is closed")
+	}
+	_, snapCost, err := astar.FindPathCost(snap, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapCost != cost {
+		t.Fatalf("Expected the snapshot to find a path costing %f instead of %f", cost, snapCost)
+	}
+}
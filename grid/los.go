@@ -0,0 +1,69 @@
+package grid
+
+import (
+	"github.com/samuel/go-astar/astar"
+)
+
+func sign(v int) int {
+	switch {
+	case v < 0:
+		return -1
+	case v > 0:
+		return 1
+	}
+	return 0
+}
+
+// GridLineOfSight reports whether the straight line between the centers
+// of the cells of a and b only crosses walkable cells. Every cell the
+// line passes through is checked, not just one per row or column as
+// with Bresenham's algorithm, so the line never slips between two
+// walls. Where the line passes exactly through the corner shared by four
+// cells it's blocked if both of the cells beside the corner are walls,
+// or either of them when DisallowCornerCutting is set, the same as a
+// diagonal move.
+func GridLineOfSight(g *Grid, a, b astar.Node) bool {
+	if !g.Valid(a) || !g.Valid(b) {
+		return false
+	}
+	x, y := g.XY(a)
+	x1, y1 := g.XY(b)
+	if !g.walkable(x, y) || !g.walkable(x1, y1) {
+		return false
+	}
+	dx, dy := abs(x1-x), abs(y1-y)
+	sx, sy := sign(x1-x), sign(y1-y)
+	for ix, iy := 0, 0; ix < dx || iy < dy; {
+		// The line leaves the current cell through a vertical side at
+		// (ix+1/2)/dx of its length and a horizontal one at (iy+1/2)/dy.
+		switch d := (2*ix+1)*dy - (2*iy+1)*dx; {
+		case d < 0:
+			x += sx
+			ix++
+		case d > 0:
+			y += sy
+			iy++
+		default:
+			side1, side2 := g.walkable(x+sx, y), g.walkable(x, y+sy)
+			if !side1 && !side2 || g.DisallowCornerCutting && (!side1 || !side2) {
+				return false
+			}
+			x += sx
+			y += sy
+			ix++
+			iy++
+		}
+		if !g.walkable(x, y) {
+			return false
+		}
+	}
+	return true
+}
+
+// LineOfSight is GridLineOfSight. It makes a Grid usable with
+// astar.SmoothPath, and with astar.FindPathTheta when Heuristic is
+// EuclideanDistance, since the cost of moving along a line must be its
+// heuristic cost.
+func (g *Grid) LineOfSight(a, b astar.Node) bool {
+	return GridLineOfSight(g, a, b)
+}
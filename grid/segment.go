@@ -0,0 +1,57 @@
+package grid
+
+import (
+	"github.com/samuel/go-astar/astar"
+)
+
+// Segment is a straight run of a path across a grid.
+type Segment struct {
+	Start, End astar.Node
+	Length     int // number of moves along the segment
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// direction returns the move from a to b reduced to its smallest integer
+// step, so moves in the same direction compare equal whatever their length.
+func direction(a, b astar.Node, width int) (int, int) {
+	ax, ay := int(a)%width, int(a)/width
+	bx, by := int(b)%width, int(b)/width
+	dx, dy := bx-ax, by-ay
+	if d := gcd(abs(dx), abs(dy)); d > 1 {
+		dx, dy = dx/d, dy/d
+	}
+	return dx, dy
+}
+
+// CompressPath merges the consecutive moves of a path across a grid of
+// the given width that go in the same direction into segments, which
+// takes much less space than every node of a path with long straight
+// runs. Node y*width+x is the cell at (x, y). Moves don't need to be
+// between adjacent cells. A path of fewer than two nodes has no
+// segments.
+func CompressPath(path []astar.Node, width int) []Segment {
+	if len(path) < 2 {
+		return nil
+	}
+	var segments []Segment
+	seg := Segment{Start: path[0], End: path[1], Length: 1}
+	dx, dy := direction(path[0], path[1], width)
+	for i := 2; i < len(path); i++ {
+		ndx, ndy := direction(path[i-1], path[i], width)
+		if ndx == dx && ndy == dy {
+			seg.End = path[i]
+			seg.Length++
+			continue
+		}
+		segments = append(segments, seg)
+		seg = Segment{Start: path[i-1], End: path[i], Length: 1}
+		dx, dy = ndx, ndy
+	}
+	return append(segments, seg)
+}
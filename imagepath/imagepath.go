@@ -0,0 +1,199 @@
+// Package imagepath finds paths across images with astar, preferring to
+// move between pixels of similar luminance.
+package imagepath
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/samuel/go-astar/astar"
+)
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// Connectivity selects which neighboring pixels a path may move to.
+type Connectivity int
+
+const (
+	// Eight allows moving to the 8 surrounding pixels including diagonals.
+	Eight Connectivity = iota
+	// Four only allows moving horizontally and vertically.
+	Four
+)
+
+// ImageMap is a graph of the pixels of an image. Node y*Width+x is the
+// pixel at (x, y) and the cost of moving between neighboring pixels
+// grows with the difference in their luminance, so paths follow regions
+// of similar color.
+type ImageMap struct {
+	Pix              []byte
+	YStride, XStride int
+	Width, Height    int
+	Stddev           float64
+	Connectivity     Connectivity
+	// CostFunc returns the cost of moving between pixels of two colors,
+	// which is added to the distance between them. If it's nil the cost
+	// is the squared difference in luminance. It needs the image, so it's
+	// ignored by an ImageMap that wasn't returned by NewImageMap.
+	CostFunc func(a, b color.Color) float64
+
+	img    image.Image
+	setter func(x, y int, c color.Color)
+}
+
+func colorCost(c1, c2 byte) float64 {
+	a := abs(int(c1) - int(c2))
+	return float64(a * a)
+}
+
+// NewImageMap returns an 8-connected ImageMap for the luminance of the
+// image. Only *image.YCbCr, *image.RGBA and *image.Gray images are
+// supported. Setting pixels through the ImageMap modifies the image.
+func NewImageMap(img image.Image) (*ImageMap, error) {
+	var im *ImageMap
+	switch m := img.(type) {
+	case *image.YCbCr:
+		im = &ImageMap{
+			Pix:     m.Y,
+			YStride: m.YStride,
+			XStride: 1,
+			Width:   img.Bounds().Dx(),
+			Height:  img.Bounds().Dy(),
+			Stddev:  1.0,
+		}
+		var verticalRes, horizontalRes int
+		switch m.SubsampleRatio {
+		case image.YCbCrSubsampleRatio420:
+			verticalRes = 2
+			horizontalRes = 2
+		case image.YCbCrSubsampleRatio422:
+			verticalRes = 1
+			horizontalRes = 2
+		case image.YCbCrSubsampleRatio440:
+			verticalRes = 2
+			horizontalRes = 1
+		case image.YCbCrSubsampleRatio444:
+			verticalRes = 1
+			horizontalRes = 1
+		default:
+			return nil, errors.New("unsupported YCbCr subsample ratio")
+		}
+		im.setter = func(x, y int, c color.Color) {
+			r, g, b, _ := c.RGBA()
+			yc, cb, cr := color.RGBToYCbCr(uint8(r>>8), uint8(g>>8), uint8(b>>8))
+			m.Y[y*m.YStride+x] = yc
+			off := y/verticalRes*m.CStride + x/horizontalRes
+			m.Cb[off] = cb
+			m.Cr[off] = cr
+		}
+	case *image.RGBA:
+		im = &ImageMap{
+			Pix:     m.Pix[1:],
+			YStride: m.Stride,
+			XStride: 4,
+			Width:   img.Bounds().Dx(),
+			Height:  img.Bounds().Dy(),
+			Stddev:  1.0,
+			setter:  m.Set,
+		}
+	case *image.Gray:
+		im = &ImageMap{
+			Pix:     m.Pix,
+			YStride: m.Stride,
+			XStride: 1,
+			Width:   img.Bounds().Dx(),
+			Height:  img.Bounds().Dy(),
+			Stddev:  1.0,
+			setter:  m.Set,
+		}
+	default:
+		return nil, errors.New("Unsupported image format")
+	}
+
+	m := -1.0
+	s := 0.0
+	count := 0
+	for y := 0; y < im.Height; y++ {
+		for x := 0; x < im.Width; x++ {
+			count++
+			v := float64(im.Pix[y*im.YStride+x*im.XStride])
+			oldM := m
+			if oldM == -1 {
+				m = v
+				s = 0
+			} else {
+				m = oldM + ((v - oldM) / float64(count))
+				s += (v - oldM) * (v - m)
+			}
+		}
+	}
+	stddev := math.Sqrt(s / float64(count-1))
+	im.Stddev = stddev
+	im.img = img
+
+	return im, nil
+}
+
+func (im *ImageMap) Neighbors(node astar.Node, edges []astar.Edge) ([]astar.Edge, error) {
+	x, y := astar.UnpackXY(node, im.Width)
+	diagonal := im.Connectivity != Four
+
+	if x > 0 {
+		edges = append(edges, astar.Edge{Node: node - 1, Cost: 1 + im.pixelCost(x, y, x-1, y)})
+		if diagonal && y > 0 {
+			edges = append(edges, astar.Edge{Node: node - 1 - astar.Node(im.Width), Cost: math.Sqrt2 + im.pixelCost(x, y, x-1, y-1)})
+		}
+		if diagonal && y < im.Height-1 {
+			edges = append(edges, astar.Edge{Node: node - 1 + astar.Node(im.Width), Cost: math.Sqrt2 + im.pixelCost(x, y, x-1, y+1)})
+		}
+	}
+	if x < im.Width-1 {
+		edges = append(edges, astar.Edge{Node: node + 1, Cost: 1 + im.pixelCost(x, y, x+1, y)})
+		if diagonal && y > 0 {
+			edges = append(edges, astar.Edge{Node: node + 1 - astar.Node(im.Width), Cost: math.Sqrt2 + im.pixelCost(x, y, x+1, y-1)})
+		}
+		if diagonal && y < im.Height-1 {
+			edges = append(edges, astar.Edge{Node: node + 1 + astar.Node(im.Width), Cost: math.Sqrt2 + im.pixelCost(x, y, x+1, y+1)})
+		}
+	}
+	if y > 0 {
+		edges = append(edges, astar.Edge{Node: node - astar.Node(im.Width), Cost: 1 + im.pixelCost(x, y, x, y-1)})
+	}
+	if y < im.Height-1 {
+		edges = append(edges, astar.Edge{Node: node + astar.Node(im.Width), Cost: 1 + im.pixelCost(x, y, x, y+1)})
+	}
+	return edges, nil
+}
+
+// pixelCost returns the cost of moving from pixel (x1, y1) to (x2, y2)
+// on top of the distance between them.
+func (im *ImageMap) pixelCost(x1, y1, x2, y2 int) float64 {
+	if im.CostFunc != nil && im.img != nil {
+		min := im.img.Bounds().Min
+		return im.CostFunc(im.img.At(min.X+x1, min.Y+y1), im.img.At(min.X+x2, min.Y+y2))
+	}
+	return colorCost(im.Pix[y1*im.YStride+x1*im.XStride], im.Pix[y2*im.YStride+x2*im.XStride])
+}
+
+func (im *ImageMap) HeuristicCost(start, end astar.Node) (float64, error) {
+	endX, endY := astar.UnpackXY(end, im.Width)
+	startX, startY := astar.UnpackXY(start, im.Width)
+	a := abs(endY - startY)
+	b := abs(endX - startX)
+	if im.Connectivity == Four {
+		return float64(a + b), nil
+	}
+	return math.Sqrt(float64(a*a + b*b)), nil // * im.Stddev / 2, nil
+}
+
+// Set sets the color of the pixel at (x, y) in the underlying image.
+func (im *ImageMap) Set(x, y int, c color.Color) {
+	im.setter(x, y, c)
+}
@@ -0,0 +1,152 @@
+package imagepath
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/samuel/go-astar/astar"
+)
+
+// testImage returns a gray image with a dark band across it
+func testImage(width, height int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if x == y {
+				img.Pix[y*img.Stride+x] = 20
+			} else {
+				img.Pix[y*img.Stride+x] = 200
+			}
+		}
+	}
+	return img
+}
+
+func TestConnectivity(t *testing.T) {
+	img := testImage(10, 10)
+	im, err := NewImageMap(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	end := astar.Node(len(img.Pix) - 1)
+
+	path, err := astar.FindPath(im, 0, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) != 10 {
+		t.Fatalf("Expected an 8-connected path of 10 pixels along the diagonal instead of %v", path)
+	}
+
+	im.Connectivity = Four
+	path, err = astar.FindPath(im, 0, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) != 19 {
+		t.Fatalf("Expected a 4-connected path of 19 pixels instead of %v", path)
+	}
+	for i := 1; i < len(path); i++ {
+		dx := abs(int(path[i])%im.Width - int(path[i-1])%im.Width)
+		dy := abs(int(path[i])/im.Width - int(path[i-1])/im.Width)
+		if dx+dy != 1 {
+			t.Fatalf("Path moves diagonally from %d to %d: %v", path[i-1], path[i], path)
+		}
+	}
+	if h, _ := im.HeuristicCost(0, end); h != 18 {
+		t.Fatalf("Expected a Manhattan heuristic of 18 instead of %f", h)
+	}
+}
+
+func TestCostFunc(t *testing.T) {
+	img := testImage(10, 10)
+	im, err := NewImageMap(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	end := astar.Node(len(img.Pix) - 1)
+	edges, err := im.Neighbors(0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range edges {
+		// The default cost is the squared luminance difference
+		expected := 1 + 180.0*180.0
+		if e.Node == 11 {
+			expected = math.Sqrt2
+		}
+		if math.Abs(e.Cost-expected) > 1e-9 {
+			t.Fatalf("Expected cost %f to node %d instead of %f", expected, e.Node, e.Cost)
+		}
+	}
+
+	// Prefer bright pixels which avoids the dark diagonal
+	im.CostFunc = func(a, b color.Color) float64 {
+		return float64(255 - color.GrayModel.Convert(b).(color.Gray).Y)
+	}
+	path, err := astar.FindPath(im, 0, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range path[1 : len(path)-1] {
+		if int(n)%im.Width == int(n)/im.Width {
+			t.Fatalf("Path goes through the dark pixel %d: %v", n, path)
+		}
+	}
+
+	// Without an image CostFunc is ignored
+	bare := &ImageMap{Pix: img.Pix, XStride: 1, YStride: img.Stride, Width: 10, Height: 10, CostFunc: im.CostFunc}
+	bareEdges, err := bare.Neighbors(0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, e := range bareEdges {
+		if e != edges[i] {
+			t.Fatalf("Expected edge %v instead of %v", edges[i], e)
+		}
+	}
+}
+
+func TestFindSeam(t *testing.T) {
+	// A flat column at x=6 in a noisy image
+	img := image.NewGray(image.Rect(0, 0, 10, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 10; x++ {
+			img.Pix[y*img.Stride+x] = uint8((x*37 + y*91) % 251)
+			if x >= 5 && x <= 7 {
+				img.Pix[y*img.Stride+x] = 100
+			}
+		}
+	}
+	seam, err := FindSeam(img, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seam) != 8 {
+		t.Fatalf("Expected a pixel for each of 8 rows instead of %v", seam)
+	}
+	for y, x := range seam {
+		if x != 6 {
+			t.Fatalf("Expected the seam to follow x=6 instead of x=%d in row %d: %v", x, y, seam)
+		}
+	}
+
+	seam, err = FindSeam(img, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seam) != 10 {
+		t.Fatalf("Expected a pixel for each of 10 columns instead of %v", seam)
+	}
+	for x := 1; x < len(seam); x++ {
+		if d := seam[x] - seam[x-1]; d < -1 || d > 1 || seam[x] < 0 || seam[x] >= 8 {
+			t.Fatalf("Seam isn't connected at column %d: %v", x, seam)
+		}
+	}
+
+	if _, err := FindSeam(image.NewGray(image.Rect(0, 0, 0, 0)), true); err == nil {
+		t.Fatal("Expected an error for an empty image")
+	}
+}
@@ -0,0 +1,105 @@
+package imagepath
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/samuel/go-astar/astar"
+)
+
+var errEmptyImage = errors.New("imagepath: image is empty")
+
+// seamGraph connects each pixel to the 3 adjacent pixels in the next
+// line of a seam. Lines are rows for vertical seams and columns for
+// horizontal ones, so pixel (i, line) is node line*width+i where i is the
+// position across the line. Two extra nodes come before the first line
+// and after the last one.
+type seamGraph struct {
+	energy        []float64 // by node
+	width, length int
+}
+
+func (g *seamGraph) source() astar.Node {
+	return astar.Node(g.width * g.length)
+}
+
+func (g *seamGraph) sink() astar.Node {
+	return g.source() + 1
+}
+
+func (g *seamGraph) Neighbors(node astar.Node, edges []astar.Edge) ([]astar.Edge, error) {
+	if node == g.source() {
+		for i := 0; i < g.width; i++ {
+			edges = append(edges, astar.Edge{Node: astar.Node(i), Cost: g.energy[i]})
+		}
+		return edges, nil
+	}
+	i, line := astar.UnpackXY(node, g.width)
+	if line == g.length-1 {
+		return append(edges, astar.Edge{Node: g.sink()}), nil
+	}
+	next := (line + 1) * g.width
+	for j := i - 1; j <= i+1; j++ {
+		if j >= 0 && j < g.width {
+			edges = append(edges, astar.Edge{Node: astar.Node(next + j), Cost: g.energy[next+j]})
+		}
+	}
+	return edges, nil
+}
+
+func (g *seamGraph) HeuristicCost(start, end astar.Node) (float64, error) {
+	return 0, nil
+}
+
+func (g *seamGraph) NodeCount() int {
+	return g.width*g.length + 2
+}
+
+// FindSeam returns the connected seam of pixels with the lowest total
+// energy across the image as used by seam carving for content-aware
+// resizing. A vertical seam runs from the top to the bottom of the image
+// and has one pixel in every row. It's returned as the x coordinate of
+// its pixel in each row, relative to the bounds of the image. A
+// horizontal seam runs from left to right and is returned as the y
+// coordinate of its pixel in each column. The energy of a pixel is the
+// magnitude of the luminance gradient around it so seams avoid edges.
+func FindSeam(img image.Image, vertical bool) ([]int, error) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= 0 || h <= 0 {
+		return nil, errEmptyImage
+	}
+	g := &seamGraph{width: w, length: h}
+	if !vertical {
+		g.width, g.length = h, w
+	}
+	g.energy = make([]float64, w*h)
+	lum := func(x, y int) float64 {
+		x = min(max(x, 0), w-1)
+		y = min(max(y, 0), h-1)
+		return float64(color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray).Y)
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			e := math.Abs(lum(x+1, y)-lum(x-1, y)) + math.Abs(lum(x, y+1)-lum(x, y-1))
+			if vertical {
+				g.energy[y*w+x] = e
+			} else {
+				g.energy[x*h+y] = e
+			}
+		}
+	}
+	path, err := astar.FindPath(g, g.source(), g.sink())
+	if err != nil {
+		return nil, err
+	}
+	// Drop the source and sink
+	path = path[1 : len(path)-1]
+	seam := make([]int, len(path))
+	for i, n := range path {
+		seam[i] = int(n) % g.width
+	}
+	return seam, nil
+}